@@ -0,0 +1,86 @@
+package annex
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/annexsh/annex-sdk-go/internal/test"
+)
+
+// Artifact is a reference to a file attached to a test or case execution.
+type Artifact = test.Artifact
+
+// ArtifactSink stores the content of artifacts attached with TestT.Attach and
+// CaseT.Attach.
+type ArtifactSink = test.ArtifactSink
+
+type localArtifactSink struct {
+	dir string
+}
+
+// NewLocalArtifactSink returns an artifact sink that writes artifacts to
+// <dir>/<test execution id>/<case>/<name>, where case is "test" for artifacts
+// attached by the test body.
+func NewLocalArtifactSink(dir string) (ArtifactSink, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(abs, 0o755); err != nil {
+		return nil, err
+	}
+	return &localArtifactSink{dir: abs}, nil
+}
+
+func (s *localArtifactSink) StoreArtifact(_ context.Context, artifact Artifact, r io.Reader) (string, error) {
+	p := filepath.Join(s.dir, filepath.FromSlash(artifactKey(artifact)))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return "", err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return "", err
+	}
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err = f.Close(); err != nil {
+		return "", err
+	}
+	return "file://" + filepath.ToSlash(p), nil
+}
+
+type blobArtifactSink struct {
+	store BlobStore
+}
+
+// NewBlobArtifactSink returns an artifact sink that puts artifacts in a blob
+// store under the key <test execution id>/<case>/<name>.
+func NewBlobArtifactSink(store BlobStore) ArtifactSink {
+	return &blobArtifactSink{store: store}
+}
+
+func (s *blobArtifactSink) StoreArtifact(ctx context.Context, artifact Artifact, r io.Reader) (string, error) {
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, r); err != nil {
+		return "", err
+	}
+	key := artifactKey(artifact)
+	if err := s.store.Put(ctx, key, buf.Bytes()); err != nil {
+		return "", err
+	}
+	return "blob:" + key, nil
+}
+
+func artifactKey(artifact Artifact) string {
+	scope := "test"
+	if !artifact.CaseExecutionID.IsEmpty() {
+		scope = artifact.CaseExecutionID.ActivityID()
+	}
+	return path.Join(artifact.TestExecutionID, scope, filepath.ToSlash(artifact.Name))
+}
@@ -0,0 +1,240 @@
+package annex
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/annexsh/annex-sdk-go/internal/s3"
+)
+
+const (
+	encodingBlobRef = "annex/blob-ref"
+
+	// DefaultOffloadThreshold is the payload size above which NewOffloadCodec
+	// offloads payloads when no threshold is given.
+	DefaultOffloadThreshold = 128 * 1024
+
+	blobRequestTimeout = time.Minute
+)
+
+// ErrBlobNotFound is returned by a BlobStore when a key does not exist.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore stores payloads offloaded by NewOffloadCodec.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+type blobRef struct {
+	Key  string `json:"key"`
+	Size int    `json:"size"`
+}
+
+type offloadCodec struct {
+	store     BlobStore
+	threshold int
+}
+
+// NewOffloadCodec returns a codec that stores payloads larger than threshold
+// bytes in the blob store and replaces them with a reference that is resolved
+// when decoding. Blobs are keyed by content hash, so re-encoding the same
+// payload, e.g. on workflow replay, reuses the stored blob. A threshold of
+// zero uses DefaultOffloadThreshold.
+func NewOffloadCodec(store BlobStore, threshold int) converter.PayloadCodec {
+	if threshold <= 0 {
+		threshold = DefaultOffloadThreshold
+	}
+	return &offloadCodec{
+		store:     store,
+		threshold: threshold,
+	}
+}
+
+func (c *offloadCodec) Encode(payloads []*common.Payload) ([]*common.Payload, error) {
+	result := make([]*common.Payload, len(payloads))
+	for i, p := range payloads {
+		b, err := proto.Marshal(p)
+		if err != nil {
+			return payloads, err
+		}
+		if len(b) <= c.threshold {
+			result[i] = p
+			continue
+		}
+
+		hash := sha256.Sum256(b)
+		ref := blobRef{
+			Key:  hex.EncodeToString(hash[:]),
+			Size: len(b),
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), blobRequestTimeout)
+		err = c.store.Put(ctx, ref.Key, b)
+		cancel()
+		if err != nil {
+			return payloads, fmt.Errorf("failed to offload payload: %w", err)
+		}
+
+		data, err := json.Marshal(ref)
+		if err != nil {
+			return payloads, err
+		}
+		result[i] = &common.Payload{
+			Metadata: map[string][]byte{converter.MetadataEncoding: []byte(encodingBlobRef)},
+			Data:     data,
+		}
+	}
+	return result, nil
+}
+
+func (c *offloadCodec) Decode(payloads []*common.Payload) ([]*common.Payload, error) {
+	result := make([]*common.Payload, len(payloads))
+	for i, p := range payloads {
+		if string(p.Metadata[converter.MetadataEncoding]) != encodingBlobRef {
+			result[i] = p
+			continue
+		}
+
+		var ref blobRef
+		if err := json.Unmarshal(p.Data, &ref); err != nil {
+			return payloads, fmt.Errorf("invalid payload blob reference: %w", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), blobRequestTimeout)
+		b, err := c.store.Get(ctx, ref.Key)
+		cancel()
+		if err != nil {
+			return payloads, fmt.Errorf("failed to load offloaded payload %s: %w", ref.Key, err)
+		}
+
+		result[i] = &common.Payload{}
+		if err = proto.Unmarshal(b, result[i]); err != nil {
+			return payloads, err
+		}
+	}
+	return result, nil
+}
+
+type localBlobStore struct {
+	dir string
+}
+
+// NewLocalBlobStore returns a blob store that keeps blobs as files in dir. It
+// is only suitable when every runner of a test suite shares the directory.
+func NewLocalBlobStore(dir string) (BlobStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &localBlobStore{dir: dir}, nil
+}
+
+func (s *localBlobStore) Put(_ context.Context, key string, data []byte) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so that readers never see a partial blob.
+	tmp, err := os.CreateTemp(s.dir, ".blob-*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s *localBlobStore) Get(_ context.Context, key string) ([]byte, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return b, err
+}
+
+func (s *localBlobStore) path(key string) (string, error) {
+	if !filepath.IsLocal(key) {
+		return "", fmt.Errorf("invalid blob key: %s", key)
+	}
+	p := filepath.Join(s.dir, key)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+// S3BlobStoreConfig configures a blob store backed by S3 compatible object
+// storage, e.g. AWS S3 or MinIO.
+type S3BlobStoreConfig struct {
+	Bucket          string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string       // optional
+	Endpoint        string       // optional: defaults to AWS S3 for the region
+	UsePathStyle    bool         // optional: required by most S3 compatible stores
+	Prefix          string       // optional: key prefix for stored blobs
+	HTTPClient      *http.Client // optional
+}
+
+type s3BlobStore struct {
+	client *s3.Client
+	prefix string
+}
+
+// NewS3BlobStore returns a blob store backed by S3 compatible object storage.
+func NewS3BlobStore(cfg S3BlobStoreConfig) (BlobStore, error) {
+	client, err := s3.NewClient(s3.Config{
+		Endpoint:        cfg.Endpoint,
+		Region:          cfg.Region,
+		Bucket:          cfg.Bucket,
+		AccessKeyID:     cfg.AccessKeyID,
+		SecretAccessKey: cfg.SecretAccessKey,
+		SessionToken:    cfg.SessionToken,
+		UsePathStyle:    cfg.UsePathStyle,
+		HTTPClient:      cfg.HTTPClient,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s3BlobStore{
+		client: client,
+		prefix: cfg.Prefix,
+	}, nil
+}
+
+func (s *s3BlobStore) Put(ctx context.Context, key string, data []byte) error {
+	return s.client.PutObject(ctx, path.Join(s.prefix, key), data)
+}
+
+func (s *s3BlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	b, err := s.client.GetObject(ctx, path.Join(s.prefix, key))
+	if errors.Is(err, s3.ErrNotFound) {
+		return nil, ErrBlobNotFound
+	}
+	return b, err
+}
@@ -0,0 +1,132 @@
+package annex
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestOffloadCodec(t *testing.T) {
+	small := newTestPayload(t, "small")
+	smallSize := proto.Size(small)
+
+	tests := []struct {
+		name        string
+		threshold   int
+		payload     *common.Payload
+		wantOffload bool
+	}{
+		{
+			name:      "at threshold",
+			threshold: smallSize,
+			payload:   small,
+		},
+		{
+			name:        "above threshold",
+			threshold:   smallSize - 1,
+			payload:     small,
+			wantOffload: true,
+		},
+		{
+			name:    "below default threshold",
+			payload: newTestPayload(t, strings.Repeat("a", DefaultOffloadThreshold/2)),
+		},
+		{
+			name:        "above default threshold",
+			payload:     newTestPayload(t, strings.Repeat("a", DefaultOffloadThreshold)),
+			wantOffload: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := NewLocalBlobStore(dir)
+			require.NoError(t, err)
+			codec := NewOffloadCodec(store, tt.threshold)
+
+			encoded, err := codec.Encode([]*common.Payload{tt.payload})
+			require.NoError(t, err)
+			require.Len(t, encoded, 1)
+
+			blobs, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			if tt.wantOffload {
+				assert.Equal(t, encodingBlobRef, string(encoded[0].Metadata[converter.MetadataEncoding]))
+				assert.Len(t, blobs, 1)
+			} else {
+				assert.Same(t, tt.payload, encoded[0])
+				assert.Empty(t, blobs)
+			}
+
+			decoded, err := codec.Decode(encoded)
+			require.NoError(t, err)
+			require.Len(t, decoded, 1)
+			assert.True(t, proto.Equal(tt.payload, decoded[0]))
+		})
+	}
+}
+
+func TestOffloadCodec_ReusesBlob(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalBlobStore(dir)
+	require.NoError(t, err)
+	codec := NewOffloadCodec(store, 1)
+
+	payload := newTestPayload(t, "offloaded")
+	first, err := codec.Encode([]*common.Payload{payload})
+	require.NoError(t, err)
+	second, err := codec.Encode([]*common.Payload{payload})
+	require.NoError(t, err)
+
+	assert.True(t, proto.Equal(first[0], second[0]))
+	blobs, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, blobs, 1)
+}
+
+func TestOffloadCodec_MissingBlob(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalBlobStore(dir)
+	require.NoError(t, err)
+	codec := NewOffloadCodec(store, 1)
+
+	encoded, err := codec.Encode([]*common.Payload{newTestPayload(t, "offloaded")})
+	require.NoError(t, err)
+	blobs, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, blobs, 1)
+	require.NoError(t, os.Remove(filepath.Join(dir, blobs[0].Name())))
+
+	_, err = codec.Decode(encoded)
+	assert.ErrorIs(t, err, ErrBlobNotFound)
+	assert.ErrorContains(t, err, "failed to load offloaded payload "+blobs[0].Name())
+}
+
+func TestLocalBlobStore(t *testing.T) {
+	store, err := NewLocalBlobStore(t.TempDir())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, store.Put(ctx, "a/b", []byte("blob")))
+	got, err := store.Get(ctx, "a/b")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("blob"), got)
+
+	_, err = store.Get(ctx, "missing")
+	assert.ErrorIs(t, err, ErrBlobNotFound)
+
+	for _, key := range []string{"../escape", "/abs"} {
+		assert.ErrorContains(t, store.Put(ctx, key, []byte("blob")), "invalid blob key")
+		_, err = store.Get(ctx, key)
+		assert.ErrorContains(t, err, "invalid blob key")
+	}
+}
@@ -0,0 +1,159 @@
+package annex
+
+import (
+	"fmt"
+
+	"github.com/klauspost/compress/zstd"
+	"go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/annexsh/annex-sdk-go/internal/aesgcm"
+)
+
+const (
+	encodingEncrypted = "binary/encrypted"
+	encodingZstd      = "binary/zstd"
+)
+
+// NewDataConverter returns the default data converter wrapped with codecs.
+// Codecs are applied in order when encoding and in reverse when decoding, so
+// compression codecs should be placed before encryption codecs.
+func NewDataConverter(codecs ...converter.PayloadCodec) converter.DataConverter {
+	return converter.NewCodecDataConverter(converter.GetDefaultDataConverter(), codecs...)
+}
+
+// NewBinaryProtoDataConverter is like NewDataConverter but encodes protobuf
+// messages in the binary wire format rather than proto JSON. Both formats are
+// decoded by either converter.
+func NewBinaryProtoDataConverter(codecs ...converter.PayloadCodec) converter.DataConverter {
+	dc := converter.NewCompositeDataConverter(
+		converter.NewNilPayloadConverter(),
+		converter.NewByteSlicePayloadConverter(),
+		converter.NewProtoPayloadConverter(),
+		converter.NewProtoJSONPayloadConverter(),
+		converter.NewJSONPayloadConverter(),
+	)
+	return converter.NewCodecDataConverter(dc, codecs...)
+}
+
+// NewZlibCodec returns a codec that compresses payloads with zlib when doing
+// so reduces their size.
+func NewZlibCodec() converter.PayloadCodec {
+	return converter.NewZlibCodec(converter.ZlibCodecOptions{})
+}
+
+type zstdCodec struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+// NewZstdCodec returns a codec that compresses payloads with zstd when doing
+// so reduces their size.
+func NewZstdCodec() (converter.PayloadCodec, error) {
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &zstdCodec{
+		encoder: encoder,
+		decoder: decoder,
+	}, nil
+}
+
+func (c *zstdCodec) Encode(payloads []*common.Payload) ([]*common.Payload, error) {
+	result := make([]*common.Payload, len(payloads))
+	for i, p := range payloads {
+		b, err := proto.Marshal(p)
+		if err != nil {
+			return payloads, err
+		}
+		compressed := c.encoder.EncodeAll(b, nil)
+		// Only use the compressed payload if it is smaller than the original
+		if len(compressed) < len(b) {
+			result[i] = &common.Payload{
+				Metadata: map[string][]byte{converter.MetadataEncoding: []byte(encodingZstd)},
+				Data:     compressed,
+			}
+		} else {
+			result[i] = p
+		}
+	}
+	return result, nil
+}
+
+func (c *zstdCodec) Decode(payloads []*common.Payload) ([]*common.Payload, error) {
+	result := make([]*common.Payload, len(payloads))
+	for i, p := range payloads {
+		if string(p.Metadata[converter.MetadataEncoding]) != encodingZstd {
+			result[i] = p
+			continue
+		}
+		b, err := c.decoder.DecodeAll(p.Data, nil)
+		if err != nil {
+			return payloads, fmt.Errorf("failed to decompress payload: %w", err)
+		}
+		result[i] = &common.Payload{}
+		if err = proto.Unmarshal(b, result[i]); err != nil {
+			return payloads, err
+		}
+	}
+	return result, nil
+}
+
+type encryptionCodec struct {
+	cipher *aesgcm.Cipher
+}
+
+// NewEncryptionCodec returns a codec that encrypts payloads with AES-GCM using
+// a 16, 24 or 32 byte key. Payloads that are not encrypted, such as test
+// inputs entered in Annex, are decoded as is.
+func NewEncryptionCodec(key []byte) (converter.PayloadCodec, error) {
+	c, err := aesgcm.New(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	return &encryptionCodec{cipher: c}, nil
+}
+
+func (c *encryptionCodec) Encode(payloads []*common.Payload) ([]*common.Payload, error) {
+	result := make([]*common.Payload, len(payloads))
+	for i, p := range payloads {
+		b, err := proto.Marshal(p)
+		if err != nil {
+			return payloads, err
+		}
+		sealed, err := c.cipher.Seal(b)
+		if err != nil {
+			return payloads, err
+		}
+		result[i] = &common.Payload{
+			Metadata: map[string][]byte{converter.MetadataEncoding: []byte(encodingEncrypted)},
+			Data:     sealed,
+		}
+	}
+	return result, nil
+}
+
+func (c *encryptionCodec) Decode(payloads []*common.Payload) ([]*common.Payload, error) {
+	result := make([]*common.Payload, len(payloads))
+	for i, p := range payloads {
+		if string(p.Metadata[converter.MetadataEncoding]) != encodingEncrypted {
+			result[i] = p
+			continue
+		}
+		b, err := c.cipher.Open(p.Data)
+		if err != nil {
+			return payloads, fmt.Errorf("failed to decrypt payload: %w", err)
+		}
+		result[i] = &common.Payload{}
+		if err = proto.Unmarshal(b, result[i]); err != nil {
+			return payloads, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,173 @@
+package annex
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+	"google.golang.org/protobuf/proto"
+)
+
+var testEncryptionKey = []byte("0123456789abcdef0123456789abcdef")
+
+func newTestPayload(t *testing.T, value any) *common.Payload {
+	payload, err := converter.GetDefaultDataConverter().ToPayload(value)
+	require.NoError(t, err)
+	return payload
+}
+
+func TestCodecs_RoundTrip(t *testing.T) {
+	newZstd := func(t *testing.T) converter.PayloadCodec {
+		codec, err := NewZstdCodec()
+		require.NoError(t, err)
+		return codec
+	}
+	newEncryption := func(t *testing.T) converter.PayloadCodec {
+		codec, err := NewEncryptionCodec(testEncryptionKey)
+		require.NoError(t, err)
+		return codec
+	}
+
+	tests := []struct {
+		name         string
+		codec        func(t *testing.T) converter.PayloadCodec
+		value        any
+		wantEncoding string
+	}{
+		{
+			name:         "zstd compressible",
+			codec:        newZstd,
+			value:        strings.Repeat("annex ", 100),
+			wantEncoding: encodingZstd,
+		},
+		{
+			name:         "zstd incompressible",
+			codec:        newZstd,
+			value:        "a",
+			wantEncoding: converter.MetadataEncodingJSON,
+		},
+		{
+			name:         "encryption",
+			codec:        newEncryption,
+			value:        map[string]string{"password": "hunter22"},
+			wantEncoding: encodingEncrypted,
+		},
+		{
+			name:         "zlib",
+			codec:        func(*testing.T) converter.PayloadCodec { return NewZlibCodec() },
+			value:        strings.Repeat("annex ", 100),
+			wantEncoding: "binary/zlib",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codec := tt.codec(t)
+			payload := newTestPayload(t, tt.value)
+
+			encoded, err := codec.Encode([]*common.Payload{payload})
+			require.NoError(t, err)
+			require.Len(t, encoded, 1)
+			assert.Equal(t, tt.wantEncoding, string(encoded[0].Metadata[converter.MetadataEncoding]))
+
+			decoded, err := codec.Decode(encoded)
+			require.NoError(t, err)
+			require.Len(t, decoded, 1)
+			assert.True(t, proto.Equal(payload, decoded[0]))
+		})
+	}
+}
+
+func TestNewDataConverter_CompressThenEncrypt(t *testing.T) {
+	zstdCodec, err := NewZstdCodec()
+	require.NoError(t, err)
+	encryptionCodec, err := NewEncryptionCodec(testEncryptionKey)
+	require.NoError(t, err)
+
+	for _, dc := range []converter.DataConverter{
+		NewDataConverter(zstdCodec, encryptionCodec),
+		NewBinaryProtoDataConverter(zstdCodec, encryptionCodec),
+	} {
+		value := strings.Repeat("annex ", 100)
+		payload, err := dc.ToPayload(value)
+		require.NoError(t, err)
+		assert.Equal(t, encodingEncrypted, string(payload.Metadata[converter.MetadataEncoding]))
+		assert.NotContains(t, string(payload.Data), "annex")
+
+		var got string
+		require.NoError(t, dc.FromPayload(payload, &got))
+		assert.Equal(t, value, got)
+	}
+}
+
+func TestEncryptionCodec_Errors(t *testing.T) {
+	codec, err := NewEncryptionCodec(testEncryptionKey)
+	require.NoError(t, err)
+	encoded, err := codec.Encode([]*common.Payload{newTestPayload(t, "secret")})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		codec   func(t *testing.T) converter.PayloadCodec
+		payload func() *common.Payload
+		wantErr string
+	}{
+		{
+			name: "wrong key",
+			codec: func(t *testing.T) converter.PayloadCodec {
+				wrong, err := NewEncryptionCodec([]byte("fedcba9876543210fedcba9876543210"))
+				require.NoError(t, err)
+				return wrong
+			},
+			payload: func() *common.Payload { return encoded[0] },
+			wantErr: "failed to decrypt payload",
+		},
+		{
+			name: "tampered ciphertext",
+			payload: func() *common.Payload {
+				tampered := proto.Clone(encoded[0]).(*common.Payload)
+				tampered.Data[len(tampered.Data)-1] ^= 0xff
+				return tampered
+			},
+			wantErr: "failed to decrypt payload",
+		},
+		{
+			name: "truncated ciphertext",
+			payload: func() *common.Payload {
+				truncated := proto.Clone(encoded[0]).(*common.Payload)
+				truncated.Data = truncated.Data[:4]
+				return truncated
+			},
+			wantErr: "sealed data too short",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := codec
+			if tt.codec != nil {
+				c = tt.codec(t)
+			}
+			_, err := c.Decode([]*common.Payload{tt.payload()})
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestEncryptionCodec_DecodesUnencryptedPayloads(t *testing.T) {
+	codec, err := NewEncryptionCodec(testEncryptionKey)
+	require.NoError(t, err)
+
+	payload := newTestPayload(t, "entered in annex")
+	decoded, err := codec.Decode([]*common.Payload{payload})
+	require.NoError(t, err)
+	assert.Same(t, payload, decoded[0])
+}
+
+func TestNewEncryptionCodec_InvalidKey(t *testing.T) {
+	_, err := NewEncryptionCodec([]byte("short"))
+	assert.ErrorContains(t, err, "invalid encryption key")
+}
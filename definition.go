@@ -4,32 +4,45 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"reflect"
+	"slices"
+	"strings"
 
 	"github.com/annexsh/annex-proto/go/gen/annex/tests/v1"
 	"go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
 	"go.temporal.io/sdk/workflow"
 
 	"github.com/annexsh/annex-sdk-go/internal/name"
+	"github.com/annexsh/annex-sdk-go/internal/param"
 	"github.com/annexsh/annex-sdk-go/internal/test"
 	"github.com/annexsh/annex-sdk-go/internal/testing"
 )
 
+// ParamValidationError is the error a test fails with when its input violates
+// the constraints declared in annex struct tags or by a Validate() error
+// method. A Validate method may return it to report several fields at once.
+type ParamValidationError = param.ValidationError
+
+// ParamFieldError describes a single invalid test input field.
+type ParamFieldError = param.FieldError
+
 type simpleTest struct {
 	test func(t TestT)
 }
 
-func (f *simpleTest) workflow(ctx workflow.Context, payload *testsv1.Payload) error {
+func (f *simpleTest) workflow(ctx workflow.Context, payload *testsv1.Payload, checkpoint *test.Checkpoint) (*test.Output, error) {
 	if f.test == nil {
-		return errors.New("flow cannot be nil")
+		return nil, errors.New("flow cannot be nil")
 	}
 
-	if payload != nil {
-		return errors.New("unexpected payload received: test does not have a parameter defined")
+	if test.HasInput(payload) {
+		return nil, errors.New("unexpected payload received: test does not have a parameter defined")
 	}
 
-	return test.ExecuteTest(ctx, func(ctx workflow.Context) {
-		f.test(testing.NewT(ctx))
+	return test.ExecuteTest(ctx, payload, checkpoint, func(ctx workflow.Context) {
+		f.test(testing.NewT(ctx, checkpoint))
 	})
 }
 
@@ -39,26 +52,130 @@ func (f *simpleTest) paramType() (bool, reflect.Type) {
 
 type paramTest[P any] struct {
 	test func(t TestT, param P)
+	// defaultInput is the default input encoded with the default converter. It
+	// is decoded for every execution triggered without input data, e.g. with
+	// only the log level metadata, so that executions never share a value.
+	defaultInput *common.Payload
 }
 
-func (f *paramTest[P]) workflow(ctx workflow.Context, payload *testsv1.Payload) error {
+func newParamTest[P any](test func(t TestT, param P), defaultInput P) *paramTest[P] {
+	payload, err := converter.GetDefaultDataConverter().ToPayload(defaultInput)
+	if err != nil {
+		panic(fmt.Sprintf("failed to encode default input: %v", err))
+	}
+	return &paramTest[P]{
+		test:         test,
+		defaultInput: payload,
+	}
+}
+
+func (f *paramTest[P]) workflow(ctx workflow.Context, payload *testsv1.Payload, checkpoint *test.Checkpoint) (*test.Output, error) {
 	if f.test == nil {
-		return errors.New("test cannot be nil")
+		return nil, errors.New("test cannot be nil")
 	}
 
-	param, err := test.DecodeParam[P](payload)
+	rt := test.RuntimeFromWorkflowContext(ctx)
+
+	var input P
+	var err error
+	if test.HasInput(payload) {
+		input, err = test.DecodeParam[P](rt.Converter(), payload)
+	} else {
+		input, err = test.DecodeTemporalParam[P](converter.GetDefaultDataConverter(), f.defaultInput)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal test param: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal test param: %w", err)
+	}
+	// Secret fields are registered with the redactor before the test body can
+	// log them.
+	if err = rt.OpenSecrets(&input); err != nil {
+		return nil, fmt.Errorf("failed to decrypt test param secrets: %w", err)
+	}
+
+	if err = param.Validate(input); err != nil {
+		return nil, err
 	}
 
-	return test.ExecuteTest(ctx, func(ctx workflow.Context) {
-		f.test(testing.NewT(ctx), param)
+	return test.ExecuteTest(ctx, payload, checkpoint, func(ctx workflow.Context) {
+		f.test(testing.NewT(ctx, checkpoint), input)
 	})
 }
 
 func (f *paramTest[P]) paramType() (bool, reflect.Type) {
-	var zeroParam P
-	return true, reflect.TypeOf(zeroParam)
+	return true, reflect.TypeFor[P]()
+}
+
+type tableTest[P any] struct {
+	rows map[string]P
+	test func(t TestT, param P)
+}
+
+func (f *tableTest[P]) workflow(ctx workflow.Context, payload *testsv1.Payload, checkpoint *test.Checkpoint) (*test.Output, error) {
+	if f.test == nil {
+		return nil, errors.New("test cannot be nil")
+	}
+
+	if test.HasInput(payload) {
+		return nil, errors.New("unexpected payload received: table test runs every row with its own input")
+	}
+
+	return test.ExecuteTest(ctx, payload, checkpoint, func(ctx workflow.Context) {
+		t := testing.NewT(ctx, checkpoint)
+
+		// A row that continued as new resumes the table at that row with the
+		// rows before it already finished.
+		var resume *test.TableCheckpoint
+		if checkpoint != nil {
+			resume = checkpoint.Table
+		}
+		var failed []string
+		if resume != nil {
+			failed = slices.Clone(resume.Failed)
+		}
+
+		for _, rowName := range sortedRowNames(f.rows) {
+			if resume != nil && rowName < resume.Row {
+				continue
+			}
+			if resume == nil || rowName > resume.Row {
+				// Only the resumed row restores the checkpoint state.
+				t.ClearCheckpointState()
+			}
+			progress := test.TableCheckpoint{Row: rowName, Failed: slices.Clone(failed)}
+			err := test.ExecuteTableRow(progress, func() {
+				row := f.rows[rowName]
+				if err := test.RuntimeFromWorkflowContext(ctx).OpenSecrets(&row); err != nil {
+					panic(err.Error())
+				}
+				if err := param.Validate(row); err != nil {
+					panic(err.Error())
+				}
+				f.test(t.Subtest(), row)
+			})
+			if err != nil {
+				failed = append(failed, rowName)
+				t.Logger().Error("table row failed", "row", rowName, "error", err)
+				continue
+			}
+			t.Logger().Info("table row passed", "row", rowName)
+		}
+
+		if len(failed) > 0 {
+			panic(fmt.Sprintf("%d of %d table rows failed: %s", len(failed), len(f.rows), strings.Join(failed, ", ")))
+		}
+	})
+}
+
+func (f *tableTest[P]) paramType() (bool, reflect.Type) {
+	return false, nil
+}
+
+func sortedRowNames[P any](rows map[string]P) []string {
+	return slices.Sorted(maps.Keys(rows))
+}
+
+func tableRowTestName(name string, rowName string) string {
+	return name + "/" + rowName
 }
 
 type simpleCase struct {
@@ -92,11 +209,17 @@ func (c *paramCase[P]) activity(ctx context.Context, payload *common.Payload) (a
 		return nil, errors.New("case cannot be nil")
 	}
 
-	param, err := test.DecodeTemporalParam[P](payload)
+	rt := test.RuntimeFromContext(ctx)
+
+	param, err := test.DecodeTemporalParam[P](rt.Converter(), payload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal case param: %w", err)
 	}
 
+	if err = rt.OpenSecrets(&param); err != nil {
+		return nil, fmt.Errorf("failed to decrypt case param secrets: %w", err)
+	}
+
 	executeCase, err := test.ExecuteCase(ctx, func(ctx context.Context) {
 		c.caseFn(testing.NewCaseT(ctx), param)
 	})
@@ -9,6 +9,7 @@ require (
 	github.com/annexsh/annex-proto/go v0.0.0-20241008104412-0c198c7724a7
 	github.com/cenkalti/backoff/v4 v4.3.0
 	github.com/google/uuid v1.6.0
+	github.com/klauspost/compress v1.17.11
 	github.com/stretchr/testify v1.9.0
 	go.temporal.io/api v1.39.0
 	go.temporal.io/sdk v1.29.1
@@ -0,0 +1,45 @@
+package aesgcm
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+)
+
+// Cipher encrypts data with AES-GCM. Sealed data is prefixed with its random
+// nonce.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// New creates a cipher with a 16, 24 or 32 byte key.
+func New(key []byte) (*Cipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Seal encrypts plaintext with a random nonce.
+func (c *Cipher) Seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Open decrypts data sealed with Seal.
+func (c *Cipher) Open(sealed []byte) ([]byte, error) {
+	nonceSize := c.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, errors.New("sealed data too short")
+	}
+	return c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+}
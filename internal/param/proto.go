@@ -0,0 +1,114 @@
+package param
+
+import (
+	"reflect"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+var protoMessageType = reflect.TypeFor[proto.Message]()
+
+// IsProtoMessage reports whether t, or a pointer to t, is a protobuf message.
+func IsProtoMessage(t reflect.Type) bool {
+	if t == nil {
+		return false
+	}
+	return t.Implements(protoMessageType) || reflect.PointerTo(t).Implements(protoMessageType)
+}
+
+// Zero returns the zero input of type t. Protobuf message pointers are
+// allocated so that the input encodes as an empty message rather than null.
+func Zero(t reflect.Type) any {
+	if t.Kind() == reflect.Pointer && t.Implements(protoMessageType) {
+		return reflect.New(t.Elem()).Interface()
+	}
+	return reflect.Zero(t).Interface()
+}
+
+func generateProtoSchema(t reflect.Type) *Schema {
+	if t.Kind() != reflect.Pointer {
+		t = reflect.PointerTo(t)
+	}
+	msg := reflect.Zero(t).Interface().(proto.Message)
+	return protoMessageSchema(msg.ProtoReflect().Descriptor(), map[protoreflect.FullName]bool{})
+}
+
+func protoMessageSchema(md protoreflect.MessageDescriptor, visiting map[protoreflect.FullName]bool) *Schema {
+	// Well-known types have special JSON encodings.
+	switch md.FullName() {
+	case "google.protobuf.Timestamp":
+		return &Schema{Type: "string", Format: "date-time"}
+	case "google.protobuf.Duration", "google.protobuf.FieldMask":
+		return &Schema{Type: "string"}
+	case "google.protobuf.Struct":
+		return &Schema{Type: "object"}
+	case "google.protobuf.ListValue":
+		return &Schema{Type: "array"}
+	case "google.protobuf.Value", "google.protobuf.Any", "google.protobuf.Empty":
+		return &Schema{}
+	case "google.protobuf.StringValue", "google.protobuf.BytesValue",
+		"google.protobuf.BoolValue", "google.protobuf.DoubleValue", "google.protobuf.FloatValue",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value",
+		"google.protobuf.Int64Value", "google.protobuf.UInt64Value":
+		return protoFieldSchema(md.Fields().ByName("value"), visiting)
+	}
+
+	if visiting[md.FullName()] {
+		// Recursive messages are left unconstrained below the first level.
+		return &Schema{Type: "object"}
+	}
+	visiting[md.FullName()] = true
+	defer delete(visiting, md.FullName())
+
+	fields := md.Fields()
+	s := &Schema{
+		Type:       "object",
+		Properties: make(map[string]*Schema, fields.Len()),
+	}
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		var fs *Schema
+		switch {
+		case fd.IsMap():
+			fs = &Schema{Type: "object", AdditionalProperties: protoFieldSchema(fd.MapValue(), visiting)}
+		case fd.IsList():
+			fs = &Schema{Type: "array", Items: protoFieldSchema(fd, visiting)}
+		default:
+			fs = protoFieldSchema(fd, visiting)
+		}
+		s.Properties[fd.JSONName()] = fs
+	}
+	return s
+}
+
+func protoFieldSchema(fd protoreflect.FieldDescriptor, visiting map[protoreflect.FullName]bool) *Schema {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return &Schema{Type: "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return &Schema{Type: "integer"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		// 64-bit integers are encoded as strings in proto JSON.
+		return &Schema{Type: "string", Format: "int64"}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return &Schema{Type: "number"}
+	case protoreflect.StringKind:
+		return &Schema{Type: "string"}
+	case protoreflect.BytesKind:
+		return &Schema{Type: "string", Format: "byte"}
+	case protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		s := &Schema{Type: "string"}
+		for i := 0; i < values.Len(); i++ {
+			s.Enum = append(s.Enum, string(values.Get(i).Name()))
+		}
+		return s
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return protoMessageSchema(fd.Message(), visiting)
+	default:
+		return &Schema{}
+	}
+}
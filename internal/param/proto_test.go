@@ -0,0 +1,57 @@
+package param
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/annexsh/annex-proto/go/gen/annex/tests/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/api/common/v1"
+	"go.temporal.io/api/failure/v1"
+	"go.temporal.io/api/taskqueue/v1"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGenerateProtoSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		typ  reflect.Type
+	}{
+		// Optional scalars and well-known timestamps.
+		{name: "publish_log_request", typ: reflect.TypeFor[*testsv1.PublishLogRequest]()},
+		// Repeated messages, maps and bytes.
+		{name: "payloads", typ: reflect.TypeFor[*common.Payloads]()},
+		// Enums and non-pointer message types.
+		{name: "task_queue", typ: reflect.TypeFor[taskqueue.TaskQueue]()},
+		// Recursive messages, oneofs and 64-bit integers.
+		{name: "failure", typ: reflect.TypeFor[*failure.Failure]()},
+		{name: "duration", typ: reflect.TypeFor[*durationpb.Duration]()},
+		{name: "struct", typ: reflect.TypeFor[*structpb.Struct]()},
+		{name: "int64_value", typ: reflect.TypeFor[*wrapperspb.Int64Value]()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.MarshalIndent(generateProtoSchema(tt.typ), "", "  ")
+			require.NoError(t, err)
+
+			golden := filepath.Join("testdata", "proto_schema", tt.name+".json")
+			if *update {
+				require.NoError(t, os.MkdirAll(filepath.Dir(golden), 0o755))
+				require.NoError(t, os.WriteFile(golden, append(got, '\n'), 0o644))
+			}
+			want, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(want), string(got))
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package param
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+const schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema used to describe test parameters.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	WriteOnly            bool               `json:"writeOnly,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	MinProperties        *int               `json:"minProperties,omitempty"`
+	MaxProperties        *int               `json:"maxProperties,omitempty"`
+}
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// GenerateSchema generates a JSON Schema describing how t is encoded as JSON.
+func GenerateSchema(t reflect.Type) (*Schema, error) {
+	s, err := generateSchema(t, map[reflect.Type]bool{})
+	if err != nil {
+		return nil, err
+	}
+	s.Schema = schemaDraft
+	return s, nil
+}
+
+// MarshalSchema generates the JSON Schema of t and marshals it.
+func MarshalSchema(t reflect.Type) ([]byte, error) {
+	s, err := GenerateSchema(t)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(s)
+}
+
+func generateSchema(t reflect.Type, visiting map[reflect.Type]bool) (*Schema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case IsProtoMessage(t):
+		return generateProtoSchema(t), nil
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}, nil
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// Custom encoding can't be described, so accept any value.
+		return &Schema{}, nil
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}, nil
+		}
+		items, err := generateSchema(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		s := &Schema{Type: "array", Items: items}
+		if t.Kind() == reflect.Array {
+			n := t.Len()
+			s.MinItems, s.MaxItems = &n, &n
+		}
+		return s, nil
+	case reflect.Map:
+		values, err := generateSchema(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		return generateStructSchema(t, visiting)
+	default:
+		return nil, fmt.Errorf("unsupported parameter type %s", t)
+	}
+}
+
+func generateStructSchema(t reflect.Type, visiting map[reflect.Type]bool) (*Schema, error) {
+	if visiting[t] {
+		// Recursive types are left unconstrained below the first level.
+		return &Schema{Type: "object"}, nil
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	fields, err := Fields(t)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Schema{
+		Type:       "object",
+		Properties: make(map[string]*Schema, len(fields)),
+	}
+
+	for _, f := range fields {
+		fs, err := generateSchema(f.Type, visiting)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		if err = applyTag(fs, f.Tag); err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		s.Properties[f.JSONName] = fs
+		if f.Tag.Required {
+			s.Required = append(s.Required, f.JSONName)
+		}
+	}
+
+	return s, nil
+}
+
+func applyTag(s *Schema, tag Tag) error {
+	s.Description = tag.Description
+
+	if tag.Secret {
+		s.Format = "password"
+		s.WriteOnly = true
+	}
+
+	for _, v := range tag.Enum {
+		ev, err := enumValue(s.Type, v)
+		if err != nil {
+			return err
+		}
+		s.Enum = append(s.Enum, ev)
+	}
+
+	switch s.Type {
+	case "integer", "number":
+		s.Minimum, s.Maximum = tag.Min, tag.Max
+	case "string":
+		s.MinLength, s.MaxLength = toInt(tag.Min), toInt(tag.Max)
+	case "array":
+		// Fixed size arrays keep their length unless the tag narrows it.
+		if tag.Min != nil {
+			s.MinItems = toInt(tag.Min)
+		}
+		if tag.Max != nil {
+			s.MaxItems = toInt(tag.Max)
+		}
+	case "object":
+		// Validation only checks the length of maps, not structs.
+		if s.AdditionalProperties == nil && (tag.Min != nil || tag.Max != nil) {
+			return fmt.Errorf("min/max is not supported for structs")
+		}
+		s.MinProperties, s.MaxProperties = toInt(tag.Min), toInt(tag.Max)
+	default:
+		if tag.Min != nil || tag.Max != nil {
+			return fmt.Errorf("min/max is not supported for type %q", s.Type)
+		}
+	}
+
+	return nil
+}
+
+func enumValue(schemaType string, v string) (any, error) {
+	switch schemaType {
+	case "integer":
+		return strconv.ParseInt(v, 10, 64)
+	case "number":
+		return strconv.ParseFloat(v, 64)
+	case "boolean":
+		return strconv.ParseBool(v)
+	case "string":
+		return v, nil
+	default:
+		return nil, fmt.Errorf("enum is not supported for type %q", schemaType)
+	}
+}
+
+func toInt(f *float64) *int {
+	if f == nil {
+		return nil
+	}
+	i := int(*f)
+	return &i
+}
@@ -0,0 +1,163 @@
+package param
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type schemaParam struct {
+	Name     string            `json:"name" annex:"required,min=1,max=20,description=Name, shown in reports"`
+	Region   string            `json:"region,omitempty" annex:"enum=eu|us"`
+	Retries  int               `json:"retries" annex:"enum=1|3,min=1"`
+	Ratio    float64           `json:"ratio" annex:"max=1"`
+	Token    string            `json:"token" annex:"secret"`
+	Hosts    []string          `json:"hosts" annex:"min=1"`
+	Labels   map[string]string `json:"labels" annex:"max=5"`
+	Deadline time.Time         `json:"deadline"`
+	Raw      []byte            `json:"raw"`
+	Pair     [2]int            `json:"pair"`
+	Any      any               `json:"any"`
+	Next     *schemaParam      `json:"next"`
+	Skipped  string            `json:"-"`
+	internal string
+	schemaEmbedded
+}
+
+type schemaEmbedded struct {
+	Verbose bool
+}
+
+func TestGenerateSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     reflect.Type
+		want    string
+		wantErr string
+	}{
+		{
+			name: "scalar",
+			typ:  reflect.TypeFor[int64](),
+			want: `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"integer"}`,
+		},
+		{
+			name: "struct",
+			typ:  reflect.TypeFor[schemaParam](),
+			want: `{
+				"$schema": "https://json-schema.org/draft/2020-12/schema",
+				"type": "object",
+				"required": ["name"],
+				"properties": {
+					"name": {"type": "string", "description": "Name, shown in reports", "minLength": 1, "maxLength": 20},
+					"region": {"type": "string", "enum": ["eu", "us"]},
+					"retries": {"type": "integer", "enum": [1, 3], "minimum": 1},
+					"ratio": {"type": "number", "maximum": 1},
+					"token": {"type": "string", "format": "password", "writeOnly": true},
+					"hosts": {"type": "array", "items": {"type": "string"}, "minItems": 1},
+					"labels": {"type": "object", "additionalProperties": {"type": "string"}, "maxProperties": 5},
+					"deadline": {"type": "string", "format": "date-time"},
+					"raw": {"type": "string", "format": "byte"},
+					"pair": {"type": "array", "items": {"type": "integer"}, "minItems": 2, "maxItems": 2},
+					"any": {},
+					"next": {"type": "object"},
+					"Verbose": {"type": "boolean"}
+				}
+			}`,
+		},
+		{
+			name: "proto message",
+			typ:  reflect.TypeFor[*timestamppb.Timestamp](),
+			want: `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"string","format":"date-time"}`,
+		},
+		{
+			name: "json marshaler",
+			typ:  reflect.TypeFor[jsonMarshalerParam](),
+			want: `{"$schema":"https://json-schema.org/draft/2020-12/schema"}`,
+		},
+		{
+			name:    "unsupported type",
+			typ:     reflect.TypeFor[chan int](),
+			wantErr: "unsupported parameter type chan int",
+		},
+		{
+			name: "min on struct",
+			typ: reflect.TypeFor[struct {
+				Nested struct{} `annex:"min=1"`
+			}](),
+			wantErr: "field Nested: min/max is not supported for structs",
+		},
+		{
+			name: "enum value of wrong type",
+			typ: reflect.TypeFor[struct {
+				Count int `annex:"enum=one"`
+			}](),
+			wantErr: "field Count",
+		},
+		{
+			name: "secret field not a string",
+			typ: reflect.TypeFor[struct {
+				Pin int `annex:"secret"`
+			}](),
+			wantErr: "secret field Pin must be a string",
+		},
+		{
+			name: "unknown tag option",
+			typ: reflect.TypeFor[struct {
+				Name string `annex:"optional"`
+			}](),
+			wantErr: `unknown option "optional"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MarshalSchema(tt.typ)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
+
+type jsonMarshalerParam struct{}
+
+func (jsonMarshalerParam) MarshalJSON() ([]byte, error) {
+	return []byte(`"custom"`), nil
+}
+
+func TestParseTag(t *testing.T) {
+	one, ten := 1.0, 10.0
+	tests := []struct {
+		tag     string
+		want    Tag
+		wantErr string
+	}{
+		{tag: "", want: Tag{}},
+		{tag: "required,secret", want: Tag{Required: true, Secret: true}},
+		{tag: "enum=a|b", want: Tag{Enum: []string{"a", "b"}}},
+		{tag: "min=1,max=10", want: Tag{Min: &one, Max: &ten}},
+		{tag: "required,description=a, b and c", want: Tag{Required: true, Description: "a, b and c"}},
+		{tag: "enum=", wantErr: "enum requires at least one value"},
+		{tag: "min=x", wantErr: "min must be a number"},
+		{tag: "unknown", wantErr: `unknown option "unknown"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			got, err := ParseTag(tt.tag)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
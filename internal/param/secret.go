@@ -0,0 +1,259 @@
+package param
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/annexsh/annex-sdk-go/internal/aesgcm"
+)
+
+const (
+	// RedactedValue replaces secret values in logs.
+	RedactedValue = "[REDACTED]"
+
+	sealedPrefix = "annex-secret:v1:"
+)
+
+var secretTypes sync.Map // map[reflect.Type]secretType
+
+type secretType struct {
+	has bool
+	err error
+}
+
+// HasSecrets reports whether values of type t contain any secret fields. It
+// returns an error if the annex tags of t are invalid, since its secret fields
+// can't be known.
+func HasSecrets(t reflect.Type) (bool, error) {
+	if t == nil {
+		return false, nil
+	}
+	if st, ok := secretTypes.Load(t); ok {
+		return st.(secretType).has, st.(secretType).err
+	}
+	has, err := hasSecrets(t, map[reflect.Type]bool{})
+	secretTypes.Store(t, secretType{has: has, err: err})
+	return has, err
+}
+
+func hasSecrets(t reflect.Type, visiting map[reflect.Type]bool) (bool, error) {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return hasSecrets(t.Elem(), visiting)
+	case reflect.Map:
+		return hasSecrets(t.Elem(), visiting)
+	case reflect.Struct:
+		if visiting[t] {
+			return false, nil
+		}
+		visiting[t] = true
+		fields, err := Fields(t)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", t, err)
+		}
+		has := false
+		for _, f := range fields {
+			// Every field is checked so that an invalid tag is always reported.
+			fieldHas, err := hasSecrets(f.Type, visiting)
+			if err != nil {
+				return false, err
+			}
+			has = has || f.Tag.Secret || fieldHas
+		}
+		return has, nil
+	default:
+	}
+	return false, nil
+}
+
+// Redact returns a copy of v with every secret value replaced by
+// RedactedValue. Values without secret fields are returned unchanged, and
+// values whose secret fields can't be known are replaced entirely.
+func Redact(v any) any {
+	has, err := HasSecrets(reflect.TypeOf(v))
+	if err != nil {
+		return RedactedValue
+	}
+	if !has {
+		return v
+	}
+	redacted, err := transformSecrets(v, func(string) (string, error) {
+		return RedactedValue, nil
+	})
+	if err != nil {
+		return RedactedValue
+	}
+	return redacted
+}
+
+// ClearSecrets returns a copy of v with every secret value set to empty.
+// Values without secret fields are returned unchanged.
+func ClearSecrets(v any) (any, error) {
+	if has, err := HasSecrets(reflect.TypeOf(v)); err != nil || !has {
+		return v, err
+	}
+	return transformSecrets(v, func(string) (string, error) {
+		return "", nil
+	})
+}
+
+// SecretCipher encrypts the secret fields of values stored in payloads.
+type SecretCipher struct {
+	cipher *aesgcm.Cipher
+}
+
+// NewSecretCipher creates a cipher using AES-GCM with a 16, 24 or 32 byte key.
+func NewSecretCipher(key []byte) (*SecretCipher, error) {
+	c, err := aesgcm.New(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret key: %w", err)
+	}
+	return &SecretCipher{cipher: c}, nil
+}
+
+// Seal returns a copy of v with every secret value encrypted. Values without
+// secret fields are returned unchanged.
+func (c *SecretCipher) Seal(v any) (any, error) {
+	if has, err := HasSecrets(reflect.TypeOf(v)); err != nil || !has {
+		return v, err
+	}
+	return transformSecrets(v, c.seal)
+}
+
+// Open decrypts, in place, the secret values of the value ptr points to.
+func (c *SecretCipher) Open(ptr any) error {
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("open requires a non-nil pointer")
+	}
+	if has, err := HasSecrets(rv.Type()); err != nil || !has {
+		return err
+	}
+	return walkSecrets(rv, c.open)
+}
+
+// SecretValues returns the non-empty secret values of the value ptr points to.
+func SecretValues(ptr any) []string {
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return nil
+	}
+	if has, _ := HasSecrets(rv.Type()); !has {
+		return nil
+	}
+	var values []string
+	_ = walkSecrets(rv, func(s string) (string, error) {
+		if s != "" {
+			values = append(values, s)
+		}
+		return s, nil
+	})
+	return values
+}
+
+func (c *SecretCipher) seal(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	sealed, err := c.cipher.Seal([]byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return sealedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *SecretCipher) open(s string) (string, error) {
+	encoded, ok := strings.CutPrefix(s, sealedPrefix)
+	if !ok {
+		// Not sealed, e.g. a test input entered in Annex.
+		return s, nil
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("malformed secret: %w", err)
+	}
+	plaintext, err := c.cipher.Open(sealed)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// transformSecrets deep copies v through its JSON encoding, which is how it is
+// stored in payloads, and applies fn to every secret value of the copy.
+func transformSecrets(v any, fn func(string) (string, error)) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	t := reflect.TypeOf(v)
+	cp := reflect.New(t)
+	if err = json.Unmarshal(data, cp.Interface()); err != nil {
+		return nil, err
+	}
+	if err = walkSecrets(cp, fn); err != nil {
+		return nil, err
+	}
+	return cp.Elem().Interface(), nil
+}
+
+func walkSecrets(v reflect.Value, fn func(string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return walkSecrets(v.Elem(), fn)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := walkSecrets(v.Index(i), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			// Map values are not addressable, so update a copy.
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			if err := walkSecrets(elem, fn); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, elem)
+		}
+	case reflect.Struct:
+		fields, err := Fields(v.Type())
+		if err != nil {
+			return err
+		}
+		for _, f := range fields {
+			fv, err := v.FieldByIndexErr(f.Index)
+			if err != nil {
+				continue
+			}
+			if !f.Tag.Secret {
+				if err = walkSecrets(fv, fn); err != nil {
+					return err
+				}
+				continue
+			}
+			if fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			s, err := fn(fv.String())
+			if err != nil {
+				return fmt.Errorf("field %s: %w", f.Name, err)
+			}
+			fv.SetString(s)
+		}
+	default:
+	}
+	return nil
+}
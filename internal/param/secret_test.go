@@ -0,0 +1,219 @@
+package param
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type secretCreds struct {
+	User     string  `json:"user"`
+	Password string  `json:"password" annex:"secret"`
+	Token    *string `json:"token" annex:"secret"`
+}
+
+type secretParam struct {
+	Name   string                 `json:"name"`
+	Creds  secretCreds            `json:"creds"`
+	Backup *secretCreds           `json:"backup"`
+	Hosts  []secretCreds          `json:"hosts"`
+	ByEnv  map[string]secretCreds `json:"byEnv"`
+}
+
+func newSecretParam() secretParam {
+	token := "tok-123"
+	return secretParam{
+		Name:   "prod",
+		Creds:  secretCreds{User: "admin", Password: "hunter22", Token: &token},
+		Backup: &secretCreds{User: "backup", Password: "backup-pw"},
+		Hosts:  []secretCreds{{Password: "host-pw"}, {}},
+		ByEnv:  map[string]secretCreds{"eu": {Password: "eu-pw"}},
+	}
+}
+
+func newTestCipher(t *testing.T) *SecretCipher {
+	c, err := NewSecretCipher([]byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	return c
+}
+
+func TestHasSecrets(t *testing.T) {
+	type recursive struct {
+		Next *recursive
+	}
+	type unknownOption struct {
+		Password string `annex:"secrett"`
+	}
+	type nonStringSecret struct {
+		Pin int `annex:"secret"`
+	}
+	type nestedInvalid struct {
+		Token string `annex:"secret"`
+		Inner []nonStringSecret
+	}
+
+	tests := []struct {
+		name    string
+		typ     reflect.Type
+		want    bool
+		wantErr string
+	}{
+		{name: "nil", typ: nil, want: false},
+		{name: "string", typ: reflect.TypeFor[string](), want: false},
+		{name: "struct", typ: reflect.TypeFor[secretCreds](), want: true},
+		{name: "pointer", typ: reflect.TypeFor[*secretCreds](), want: true},
+		{name: "nested", typ: reflect.TypeFor[secretParam](), want: true},
+		{name: "slice", typ: reflect.TypeFor[[]secretCreds](), want: true},
+		{name: "map", typ: reflect.TypeFor[map[string]secretCreds](), want: true},
+		{name: "recursive", typ: reflect.TypeFor[recursive](), want: false},
+		{name: "unknown option", typ: reflect.TypeFor[unknownOption](), wantErr: `unknown option "secrett"`},
+		{name: "non-string secret", typ: reflect.TypeFor[nonStringSecret](), wantErr: "secret field Pin must be a string"},
+		{name: "nested invalid", typ: reflect.TypeFor[*nestedInvalid](), wantErr: "secret field Pin must be a string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HasSecrets(tt.typ)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSecretFunctions_InvalidTags(t *testing.T) {
+	type invalid struct {
+		Pin int `annex:"secret"`
+	}
+	v := invalid{Pin: 1234}
+
+	assert.Equal(t, RedactedValue, Redact(v))
+	_, err := ClearSecrets(v)
+	assert.Error(t, err)
+	_, err = newTestCipher(t).Seal(v)
+	assert.Error(t, err)
+	assert.Error(t, newTestCipher(t).Open(&v))
+}
+
+func TestSecretCipher_SealOpen(t *testing.T) {
+	c := newTestCipher(t)
+
+	tests := []struct {
+		name string
+		v    any
+	}{
+		{name: "no secrets", v: struct{ Name string }{Name: "a"}},
+		{name: "flat", v: secretCreds{User: "admin", Password: "hunter22"}},
+		{name: "empty secret", v: secretCreds{User: "admin"}},
+		{name: "nested", v: newSecretParam()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealed, err := c.Seal(tt.v)
+			require.NoError(t, err)
+			require.IsType(t, tt.v, sealed)
+
+			for _, s := range SecretValues(ptrTo(sealed)) {
+				assert.True(t, strings.HasPrefix(s, sealedPrefix), "secret %q is not sealed", s)
+			}
+
+			opened := ptrTo(sealed)
+			require.NoError(t, c.Open(opened))
+			assert.Equal(t, tt.v, reflect.ValueOf(opened).Elem().Interface())
+		})
+	}
+}
+
+func TestSecretCipher_SealDoesNotModifyValue(t *testing.T) {
+	c := newTestCipher(t)
+	v := newSecretParam()
+
+	_, err := c.Seal(v)
+	require.NoError(t, err)
+	assert.Equal(t, newSecretParam(), v)
+}
+
+func TestSecretCipher_Open(t *testing.T) {
+	c := newTestCipher(t)
+	sealed, err := c.Seal(secretCreds{Password: "hunter22"})
+	require.NoError(t, err)
+	sealedPassword := sealed.(secretCreds).Password
+
+	other, err := NewSecretCipher([]byte("fedcba9876543210"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		cipher   *SecretCipher
+		password string
+		want     string
+		wantErr  string
+	}{
+		{name: "sealed", cipher: c, password: sealedPassword, want: "hunter22"},
+		{name: "plaintext", cipher: c, password: "entered-in-annex", want: "entered-in-annex"},
+		{name: "empty", cipher: c, password: "", want: ""},
+		{name: "malformed", cipher: c, password: sealedPrefix + "!!!", wantErr: "malformed secret"},
+		{name: "too short", cipher: c, password: sealedPrefix + "AAAA", wantErr: "failed to decrypt secret"},
+		{name: "wrong key", cipher: other, password: sealedPassword, wantErr: "failed to decrypt secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := secretCreds{Password: tt.password}
+			err := tt.cipher.Open(&v)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, v.Password)
+		})
+	}
+}
+
+func TestNewSecretCipher_InvalidKey(t *testing.T) {
+	for _, key := range [][]byte{nil, []byte("short")} {
+		_, err := NewSecretCipher(key)
+		assert.ErrorContains(t, err, "invalid secret key")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	got := Redact(newSecretParam()).(secretParam)
+	assert.Equal(t, RedactedValue, got.Creds.Password)
+	assert.Equal(t, RedactedValue, *got.Creds.Token)
+	assert.Equal(t, RedactedValue, got.Backup.Password)
+	assert.Equal(t, RedactedValue, got.Hosts[0].Password)
+	assert.Equal(t, RedactedValue, got.ByEnv["eu"].Password)
+	assert.Equal(t, "admin", got.Creds.User)
+
+	assert.Equal(t, "plain", Redact("plain"))
+}
+
+func TestClearSecrets(t *testing.T) {
+	got, err := ClearSecrets(newSecretParam())
+	require.NoError(t, err)
+	assert.Empty(t, SecretValues(ptrTo(got)))
+}
+
+func TestSecretValues(t *testing.T) {
+	v := newSecretParam()
+	assert.ElementsMatch(t,
+		[]string{"hunter22", "tok-123", "backup-pw", "host-pw", "eu-pw"},
+		SecretValues(&v),
+	)
+	assert.Nil(t, SecretValues(v))
+}
+
+func ptrTo(v any) any {
+	p := reflect.New(reflect.TypeOf(v))
+	p.Elem().Set(reflect.ValueOf(v))
+	return p.Interface()
+}
@@ -0,0 +1,150 @@
+package param
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const tagName = "annex"
+
+// Tag is a parsed annex struct tag, e.g.
+//
+//	`annex:"required,enum=eu|us,description=Region to run against"`
+//
+// Options are comma separated. Since descriptions often contain commas,
+// description consumes the remainder of the tag and must be the last option.
+type Tag struct {
+	Required bool
+	// Secret marks a string field holding a credential. Secret values are
+	// encrypted in case payloads and never published or logged.
+	Secret      bool
+	Description string
+	Enum        []string
+	Min         *float64
+	Max         *float64
+}
+
+func ParseTag(tag string) (Tag, error) {
+	var parsed Tag
+
+	for tag != "" {
+		var opt string
+		if strings.HasPrefix(tag, "description=") {
+			opt, tag = tag, ""
+		} else {
+			opt, tag, _ = strings.Cut(tag, ",")
+		}
+
+		key, val, hasVal := strings.Cut(strings.TrimSpace(opt), "=")
+		switch key {
+		case "":
+		case "required":
+			parsed.Required = true
+		case "secret":
+			parsed.Secret = true
+		case "description":
+			parsed.Description = val
+		case "enum":
+			if !hasVal || val == "" {
+				return Tag{}, fmt.Errorf("enum requires at least one value")
+			}
+			parsed.Enum = strings.Split(val, "|")
+		case "min", "max":
+			f, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				return Tag{}, fmt.Errorf("%s must be a number: %w", key, err)
+			}
+			if key == "min" {
+				parsed.Min = &f
+			} else {
+				parsed.Max = &f
+			}
+		default:
+			return Tag{}, fmt.Errorf("unknown option %q", key)
+		}
+	}
+
+	return parsed, nil
+}
+
+// Field is a struct field as it appears when encoded as JSON.
+type Field struct {
+	Name      string
+	Type      reflect.Type
+	JSONName  string
+	OmitEmpty bool
+	Tag       Tag
+	Index     []int
+}
+
+// Fields returns the JSON encoded fields of struct type t, flattening embedded
+// structs the way encoding/json does.
+func Fields(t reflect.Type) ([]Field, error) {
+	return fields(t, nil)
+}
+
+func fields(t reflect.Type, index []int) ([]Field, error) {
+	var ret []Field
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		jsonTag := sf.Tag.Get("json")
+		if jsonTag == "-" {
+			continue
+		}
+		name, jsonOpts, _ := strings.Cut(jsonTag, ",")
+		fieldIndex := append(append([]int{}, index...), i)
+
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded, err := fields(ft, fieldIndex)
+				if err != nil {
+					return nil, err
+				}
+				ret = append(ret, embedded...)
+				continue
+			}
+		}
+
+		if !sf.IsExported() {
+			continue
+		}
+
+		tag, err := ParseTag(sf.Tag.Get(tagName))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s tag on field %s: %w", tagName, sf.Name, err)
+		}
+
+		if tag.Secret && !isStringType(sf.Type) {
+			return nil, fmt.Errorf("secret field %s must be a string", sf.Name)
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+
+		ret = append(ret, Field{
+			Name:      sf.Name,
+			Type:      sf.Type,
+			JSONName:  name,
+			OmitEmpty: strings.Contains(","+jsonOpts+",", ",omitempty,"),
+			Tag:       tag,
+			Index:     fieldIndex,
+		})
+	}
+
+	return ret, nil
+}
+
+func isStringType(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.String
+}
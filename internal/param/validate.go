@@ -0,0 +1,208 @@
+package param
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validator is implemented by parameters with constraints that can't be
+// declared with struct tags.
+type Validator interface {
+	Validate() error
+}
+
+// FieldError describes a parameter field that failed validation. Field is the
+// JSON path of the field, e.g. "targets[1].region".
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError lists every parameter field that failed validation.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return "invalid test parameter: " + strings.Join(msgs, "; ")
+}
+
+// Validate checks v against the constraints declared in its annex struct tags
+// and then calls its Validate method if it implements Validator. All failures
+// are returned in a *ValidationError.
+func Validate(v any) error {
+	var verr ValidationError
+
+	if err := validateValue(reflect.ValueOf(v), "", &verr); err != nil {
+		return err
+	}
+
+	if validator, ok := asValidator(v); ok {
+		if err := validator.Validate(); err != nil {
+			var nested *ValidationError
+			if errors.As(err, &nested) {
+				verr.Errors = append(verr.Errors, nested.Errors...)
+			} else {
+				verr.Errors = append(verr.Errors, FieldError{Message: err.Error()})
+			}
+		}
+	}
+
+	if len(verr.Errors) > 0 {
+		return &verr
+	}
+	return nil
+}
+
+func asValidator(v any) (Validator, bool) {
+	if validator, ok := v.(Validator); ok {
+		return validator, true
+	}
+	// Support Validate methods with pointer receivers.
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || rv.Kind() == reflect.Pointer {
+		return nil, false
+	}
+	ptr := reflect.New(rv.Type())
+	ptr.Elem().Set(rv)
+	validator, ok := ptr.Interface().(Validator)
+	return validator, ok
+}
+
+func validateValue(v reflect.Value, path string, verr *ValidationError) error {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == timeType || IsProtoMessage(v.Type()) {
+			return nil
+		}
+		fields, err := Fields(v.Type())
+		if err != nil {
+			return err
+		}
+		for _, f := range fields {
+			fieldPath := joinPath(path, f.JSONName)
+			fv, err := v.FieldByIndexErr(f.Index)
+			if err != nil {
+				// Field of a nil embedded struct pointer, which is unset.
+				if f.Tag.Required {
+					verr.Errors = append(verr.Errors, FieldError{Field: fieldPath, Message: "is required"})
+				}
+				continue
+			}
+			for _, msg := range checkField(fv, f.Tag) {
+				verr.Errors = append(verr.Errors, FieldError{Field: fieldPath, Message: msg})
+			}
+			if err = validateValue(fv, fieldPath, verr); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateValue(v.Index(i), path+"["+strconv.Itoa(i)+"]", verr); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			if err := validateValue(v.MapIndex(key), fmt.Sprintf("%s[%v]", path, key), verr); err != nil {
+				return err
+			}
+		}
+	default:
+	}
+
+	return nil
+}
+
+func checkField(v reflect.Value, tag Tag) []string {
+	// A zero value can't be told apart from a missing one, so it counts as
+	// unset, matching the schema where constraints only apply to present
+	// properties. False is a value in its own right, so a required bool is
+	// always set. Pointers to zero values are set and still constrained.
+	if v.IsZero() && v.Kind() != reflect.Bool {
+		if tag.Required {
+			return []string{"is required"}
+		}
+		return nil
+	}
+
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	var msgs []string
+
+	if len(tag.Enum) > 0 && !slices.Contains(tag.Enum, fmt.Sprint(v.Interface())) {
+		msgs = append(msgs, fmt.Sprintf("must be one of [%s]", strings.Join(tag.Enum, ", ")))
+	}
+
+	if tag.Min == nil && tag.Max == nil {
+		return msgs
+	}
+
+	var n float64
+	var unit string
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	case reflect.String:
+		n, unit = float64(utf8.RuneCountInString(v.String())), "length"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n, unit = float64(v.Len()), "length"
+	default:
+		return msgs
+	}
+
+	subject := "must be"
+	if unit != "" {
+		subject = unit + " must be"
+	}
+	if tag.Min != nil && n < *tag.Min {
+		msgs = append(msgs, fmt.Sprintf("%s at least %v", subject, *tag.Min))
+	}
+	if tag.Max != nil && n > *tag.Max {
+		msgs = append(msgs, fmt.Sprintf("%s at most %v", subject, *tag.Max))
+	}
+
+	return msgs
+}
+
+func joinPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,245 @@
+package param
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type validateOptions struct {
+	Retries int `json:"retries" annex:"min=1"`
+}
+
+type validateParam struct {
+	Name    string            `json:"name" annex:"required"`
+	Region  string            `json:"region" annex:"enum=eu|us"`
+	Count   int               `json:"count" annex:"min=1,max=10"`
+	Ratio   float64           `json:"ratio" annex:"max=1"`
+	Tags    []string          `json:"tags" annex:"min=1"`
+	Labels  map[string]string `json:"labels" annex:"max=2"`
+	Timeout *int              `json:"timeout" annex:"min=5"`
+	Owner   *string           `json:"owner" annex:"required"`
+	Shards  int               `json:"shards" annex:"required,min=2"`
+	Enabled bool              `json:"enabled" annex:"required"`
+	Targets []validateTarget  `json:"targets"`
+	*validateOptions
+}
+
+type validateTarget struct {
+	Host string `json:"host" annex:"required"`
+}
+
+func validValidateParam() validateParam {
+	owner := "team"
+	return validateParam{
+		Name:   "smoke",
+		Region: "eu",
+		Count:  1,
+		Tags:   []string{"a"},
+		Owner:  &owner,
+		Shards: 2,
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(p *validateParam)
+		want   []FieldError
+	}{
+		{
+			name:   "valid",
+			modify: func(p *validateParam) {},
+		},
+		{
+			name:   "missing required string",
+			modify: func(p *validateParam) { p.Name = "" },
+			want:   []FieldError{{Field: "name", Message: "is required"}},
+		},
+		{
+			name:   "nil required pointer",
+			modify: func(p *validateParam) { p.Owner = nil },
+			want:   []FieldError{{Field: "owner", Message: "is required"}},
+		},
+		{
+			name: "required pointer to zero value is set",
+			modify: func(p *validateParam) {
+				empty := ""
+				p.Owner = &empty
+			},
+		},
+		{
+			name:   "optional zero string is unset",
+			modify: func(p *validateParam) { p.Region = "" },
+		},
+		{
+			name:   "string not in enum",
+			modify: func(p *validateParam) { p.Region = "ap" },
+			want:   []FieldError{{Field: "region", Message: "must be one of [eu, us]"}},
+		},
+		{
+			name:   "optional zero int is unset",
+			modify: func(p *validateParam) { p.Count = 0 },
+		},
+		{
+			name:   "int below min",
+			modify: func(p *validateParam) { p.Shards = 1 },
+			want:   []FieldError{{Field: "shards", Message: "must be at least 2"}},
+		},
+		{
+			name:   "missing required int",
+			modify: func(p *validateParam) { p.Shards = 0 },
+			want:   []FieldError{{Field: "shards", Message: "is required"}},
+		},
+		{
+			name:   "required bool may be false",
+			modify: func(p *validateParam) { p.Enabled = false },
+		},
+		{
+			name:   "int above max",
+			modify: func(p *validateParam) { p.Count = 11 },
+			want:   []FieldError{{Field: "count", Message: "must be at most 10"}},
+		},
+		{
+			name:   "float above max",
+			modify: func(p *validateParam) { p.Ratio = 1.5 },
+			want:   []FieldError{{Field: "ratio", Message: "must be at most 1"}},
+		},
+		{
+			name:   "optional nil slice is unset",
+			modify: func(p *validateParam) { p.Tags = nil },
+		},
+		{
+			name:   "empty slice below min length",
+			modify: func(p *validateParam) { p.Tags = []string{} },
+			want:   []FieldError{{Field: "tags", Message: "length must be at least 1"}},
+		},
+		{
+			name:   "map above max length",
+			modify: func(p *validateParam) { p.Labels = map[string]string{"a": "1", "b": "2", "c": "3"} },
+			want:   []FieldError{{Field: "labels", Message: "length must be at most 2"}},
+		},
+		{
+			name: "pointer to zero below min",
+			modify: func(p *validateParam) {
+				zero := 0
+				p.Timeout = &zero
+			},
+			want: []FieldError{{Field: "timeout", Message: "must be at least 5"}},
+		},
+		{
+			name:   "nil optional pointer is unset",
+			modify: func(p *validateParam) { p.Timeout = nil },
+		},
+		{
+			name:   "nested slice element",
+			modify: func(p *validateParam) { p.Targets = []validateTarget{{Host: "a"}, {}} },
+			want:   []FieldError{{Field: "targets[1].host", Message: "is required"}},
+		},
+		{
+			name:   "nil embedded struct is unset",
+			modify: func(p *validateParam) { p.validateOptions = nil },
+		},
+		{
+			name:   "zero field of embedded struct is unset",
+			modify: func(p *validateParam) { p.validateOptions = &validateOptions{} },
+		},
+		{
+			name:   "field of embedded struct below min",
+			modify: func(p *validateParam) { p.validateOptions = &validateOptions{Retries: -1} },
+			want:   []FieldError{{Field: "retries", Message: "must be at least 1"}},
+		},
+		{
+			name: "every failure is reported",
+			modify: func(p *validateParam) {
+				p.Name = ""
+				p.Count = 20
+			},
+			want: []FieldError{
+				{Field: "name", Message: "is required"},
+				{Field: "count", Message: "must be at most 10"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := validValidateParam()
+			tt.modify(&p)
+
+			err := Validate(p)
+			if tt.want == nil {
+				require.NoError(t, err)
+				return
+			}
+			var verr *ValidationError
+			require.ErrorAs(t, err, &verr)
+			assert.Equal(t, tt.want, verr.Errors)
+		})
+	}
+}
+
+type validatorParam struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+func (p *validatorParam) Validate() error {
+	if p.From > p.To {
+		return errors.New("from must not be after to")
+	}
+	return nil
+}
+
+type nestedValidatorParam struct{}
+
+func (nestedValidatorParam) Validate() error {
+	return &ValidationError{Errors: []FieldError{{Field: "a", Message: "bad"}, {Field: "b", Message: "worse"}}}
+}
+
+func TestValidate_Validator(t *testing.T) {
+	tests := []struct {
+		name  string
+		param any
+		want  []FieldError
+	}{
+		{
+			name:  "pointer receiver valid",
+			param: validatorParam{From: 1, To: 2},
+		},
+		{
+			name:  "pointer receiver invalid",
+			param: validatorParam{From: 2, To: 1},
+			want:  []FieldError{{Message: "from must not be after to"}},
+		},
+		{
+			name:  "validation error is flattened",
+			param: nestedValidatorParam{},
+			want:  []FieldError{{Field: "a", Message: "bad"}, {Field: "b", Message: "worse"}},
+		},
+		{
+			name:  "non struct",
+			param: 42,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.param)
+			if tt.want == nil {
+				require.NoError(t, err)
+				return
+			}
+			var verr *ValidationError
+			require.ErrorAs(t, err, &verr)
+			assert.Equal(t, tt.want, verr.Errors)
+		})
+	}
+}
+
+func TestValidationError_Error(t *testing.T) {
+	err := &ValidationError{Errors: []FieldError{{Field: "count", Message: "must be at least 1"}, {Message: "invalid range"}}}
+	assert.Equal(t, "invalid test parameter: count: must be at least 1; invalid range", err.Error())
+}
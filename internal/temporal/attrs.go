@@ -0,0 +1,137 @@
+package temporal
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const badKey = "!BADKEY"
+
+// Attr is a log attribute with its value rendered as text so that it can be
+// carried in a workflow log request without losing its formatting.
+type Attr struct {
+	Key   string
+	Value string
+}
+
+// keyvalsToAttrs converts alternating keys and values to slog attributes using
+// the same rules as slog: slog.Attr values are used as is and a value without
+// a key is given the key "!BADKEY".
+func keyvalsToAttrs(keyvals []any) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(keyvals)/2)
+	for len(keyvals) > 0 {
+		switch k := keyvals[0].(type) {
+		case slog.Attr:
+			attrs = append(attrs, k)
+			keyvals = keyvals[1:]
+		case string:
+			if len(keyvals) == 1 {
+				attrs = append(attrs, slog.String(badKey, k))
+				keyvals = nil
+				continue
+			}
+			attrs = append(attrs, slog.Any(k, keyvals[1]))
+			keyvals = keyvals[2:]
+		default:
+			attrs = append(attrs, slog.Any(badKey, k))
+			keyvals = keyvals[1:]
+		}
+	}
+	return attrs
+}
+
+func attrsToArgs(attrs []slog.Attr) []any {
+	args := make([]any, len(attrs))
+	for i, attr := range attrs {
+		args[i] = attr
+	}
+	return args
+}
+
+// renderAttrs renders attributes as text. Group attributes are flattened with
+// dot separated keys.
+func renderAttrs(attrs []slog.Attr) []Attr {
+	var rendered []Attr
+	var render func(prefix string, attrs []slog.Attr)
+	render = func(prefix string, attrs []slog.Attr) {
+		for _, attr := range attrs {
+			key := attr.Key
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			value := attr.Value.Resolve()
+			if value.Kind() == slog.KindGroup {
+				render(key, value.Group())
+				continue
+			}
+			rendered = append(rendered, Attr{Key: key, Value: renderValue(value)})
+		}
+	}
+	render("", attrs)
+	return rendered
+}
+
+func renderValue(v slog.Value) string {
+	switch v.Kind() {
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		switch a := v.Any().(type) {
+		case nil:
+			return "<nil>"
+		case error:
+			return a.Error()
+		case fmt.Stringer:
+			return a.String()
+		case encoding.TextMarshaler:
+			if b, err := a.MarshalText(); err == nil {
+				return string(b)
+			}
+		case []byte:
+			return string(a)
+		}
+		// Composite values are rendered as JSON so that they stay parsable.
+		if b, err := json.Marshal(v.Any()); err == nil {
+			return string(b)
+		}
+		return fmt.Sprintf("%+v", v.Any())
+	default:
+		return v.String()
+	}
+}
+
+// formatMessage appends attributes to a log message in logfmt, e.g.
+// `order created id=42 customer="Jane Doe"`. Annex logs have no attribute
+// field, so this is how attributes are published.
+func formatMessage(msg string, attrs []Attr) string {
+	if len(attrs) == 0 {
+		return msg
+	}
+	var b strings.Builder
+	b.WriteString(msg)
+	for _, attr := range attrs {
+		b.WriteByte(' ')
+		b.WriteString(quoteLogfmt(attr.Key))
+		b.WriteByte('=')
+		b.WriteString(quoteLogfmt(attr.Value))
+	}
+	return b.String()
+}
+
+func quoteLogfmt(s string) string {
+	if s == "" {
+		return `""`
+	}
+	if strings.ContainsFunc(s, func(r rune) bool {
+		return r == '=' || r == '"' || unicode.IsSpace(r) || !unicode.IsPrint(r)
+	}) {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -14,15 +14,16 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 )
 
-func NewClient(ctx context.Context, logger log.Logger, hostPort string, namespace string) (client.Client, error) {
+func NewClient(ctx context.Context, logger log.Logger, redactor *Redactor, dc converter.DataConverter, metrics client.MetricsHandler, hostPort string, namespace string) (client.Client, error) {
 	c, err := client.NewLazyClient(client.Options{
-		HostPort:      hostPort,
-		Namespace:     namespace,
-		DataConverter: converter.GetDefaultDataConverter(),
+		HostPort:       hostPort,
+		Namespace:      namespace,
+		DataConverter:  dc,
+		MetricsHandler: metrics,
 		ConnectionOptions: client.ConnectionOptions{
 			DialOptions: []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
 		},
-		Logger: FromLogger(logger),
+		Logger: NewRedactingLogger(logger, redactor),
 	})
 	if err != nil {
 		return nil, err
@@ -10,8 +10,12 @@ import (
 type testLogConfigKey struct{}
 
 type TestLogConfig struct {
+	// Context is the Annex context the test execution belongs to.
+	Context    string
 	TestExecID test.TestExecutionID
 	CaseExecID *test.CaseExecutionID
+	// LogLevels replace the runner log levels for the test execution.
+	LogLevels LogLevelOverrides
 }
 
 func ContextWithTestLogConfig(ctx context.Context, config TestLogConfig) context.Context {
@@ -2,8 +2,11 @@ package temporal
 
 import (
 	"context"
+	"time"
 
 	"github.com/annexsh/annex/log"
+	"github.com/annexsh/annex/test"
+	"go.temporal.io/sdk/activity"
 	"go.temporal.io/sdk/interceptor"
 	tlog "go.temporal.io/sdk/log"
 	"go.temporal.io/sdk/workflow"
@@ -12,13 +15,21 @@ import (
 type workerTestLogInterceptor struct {
 	interceptor.WorkerInterceptorBase
 	logger    log.Logger
-	publisher LogPublisher
+	publisher *BatchPublisher
+	levels    LogLevels
+	redactor  *Redactor
 }
 
-func NewWorkerLogInterceptor(logger log.Logger, publisher LogPublisher) interceptor.WorkerInterceptor {
+// NewWorkerLogInterceptor provides test loggers to workflows and activities.
+// Case logs are published with publisher, which is flushed when each case
+// completes. Logs are filtered by levels unless the test execution overrides
+// them, and sensitive values are masked with redactor.
+func NewWorkerLogInterceptor(logger log.Logger, publisher *BatchPublisher, levels LogLevels, redactor *Redactor) interceptor.WorkerInterceptor {
 	return &workerTestLogInterceptor{
 		logger:    logger,
 		publisher: publisher,
+		levels:    levels,
+		redactor:  redactor,
 	}
 }
 
@@ -42,7 +53,27 @@ type activityInboundLogInterceptor struct {
 	interceptor.ActivityInboundInterceptorBase
 	root      *workerTestLogInterceptor
 	logger    log.Logger
-	publisher LogPublisher
+	publisher *BatchPublisher
+}
+
+func (i *activityInboundLogInterceptor) ExecuteActivity(ctx context.Context, in *interceptor.ExecuteActivityInput) (any, error) {
+	overrides, err := readLogLevelsHeader(ctx)
+	if err != nil {
+		i.logger.Warn("failed to read log levels header", "error", err)
+	}
+	ctx = contextWithLogLevelOverrides(ctx, overrides)
+
+	res, err := i.Next.ExecuteActivity(ctx, in)
+	if _, caseErr := test.ParseCaseActivityID(activity.GetInfo(ctx).ActivityID); caseErr == nil {
+		// Publish the case logs before the case is reported complete so that
+		// they are visible alongside its result.
+		flushCtx, cancel := context.WithTimeout(context.Background(), logRequestTimeout)
+		defer cancel()
+		if flushErr := i.publisher.Flush(flushCtx); flushErr != nil {
+			i.logger.Warn("failed to flush case logs", "error", flushErr)
+		}
+	}
+	return res, err
 }
 
 func (i *activityInboundLogInterceptor) Init(outbound interceptor.ActivityOutboundInterceptor) error {
@@ -59,16 +90,21 @@ type activityOutboundInterceptor struct {
 	interceptor.ActivityOutboundInterceptorBase
 	root      *workerTestLogInterceptor
 	logger    log.Logger
-	publisher LogPublisher
+	publisher *BatchPublisher
 }
 
 func (i *activityOutboundInterceptor) GetLogger(ctx context.Context) tlog.Logger {
 	cfg, ok := TestLogConfigFromContext(ctx)
 	if ok {
-		if cfg.CaseExecID == nil {
-			return NewTestActivityLogger(i.logger, i.publisher, cfg.TestExecID)
+		overrides := logLevelOverridesFromContext(ctx).Merge(cfg.LogLevels)
+		opts := []CaseOption{
+			WithLogLevels(i.root.levels.override(overrides)),
+			WithRedactor(i.root.redactor),
+		}
+		if cfg.CaseExecID != nil {
+			opts = append(opts, WithCaseExecID(*cfg.CaseExecID))
 		}
-		return NewTestActivityLogger(i.logger, i.publisher, cfg.TestExecID, WithCaseExecID(*cfg.CaseExecID))
+		return NewTestActivityLogger(i.logger, i.publisher, cfg.Context, cfg.TestExecID, opts...)
 	}
 	return i.Next.GetLogger(ctx)
 }
@@ -77,24 +113,61 @@ type workflowInboundLogInterceptor struct {
 	interceptor.WorkflowInboundInterceptorBase
 	root   *workerTestLogInterceptor
 	logger log.Logger
+	buffer *workflowLogBuffer
 }
 
 func (i *workflowInboundLogInterceptor) Init(outbound interceptor.WorkflowOutboundInterceptor) error {
-	in := &workflowOutboundLogInterceptor{root: i.root, logger: i.logger}
+	i.buffer = newWorkflowLogBuffer(i.logger)
+	in := &workflowOutboundLogInterceptor{root: i.root, logger: i.logger, buffer: i.buffer}
 	in.Next = outbound
 	return i.Next.Init(in)
 }
 
+func (i *workflowInboundLogInterceptor) ExecuteWorkflow(ctx workflow.Context, in *interceptor.ExecuteWorkflowInput) (any, error) {
+	res, err := i.Next.ExecuteWorkflow(ctx, in)
+	// Publish the remaining logs even if the workflow was canceled.
+	flushCtx, cancel := workflow.NewDisconnectedContext(ctx)
+	defer cancel()
+	i.buffer.flush(flushCtx)
+	return res, err
+}
+
 type workflowOutboundLogInterceptor struct {
 	interceptor.WorkflowOutboundInterceptorBase
 	root   *workerTestLogInterceptor
 	logger log.Logger
+	buffer *workflowLogBuffer
 }
 
 func (i *workflowOutboundLogInterceptor) GetLogger(ctx workflow.Context) tlog.Logger {
 	cfg, ok := TestLogConfigFromWorkflowContext(ctx)
 	if ok {
-		return NewTestWorkflowLogger(ctx, i.logger, cfg.TestExecID)
+		localLevel := i.root.levels.override(cfg.LogLevels).Local
+		return newTestWorkflowLogger(ctx, i.logger, i.root.redactor, i.buffer, localLevel, cfg.LogLevels.Publish, cfg.Context, cfg.TestExecID)
 	}
 	return i.Next.GetLogger(ctx)
 }
+
+// Buffered logs are flushed before cases start and timers are set so that they
+// are published before the workflow waits.
+
+func (i *workflowOutboundLogInterceptor) ExecuteActivity(ctx workflow.Context, activityType string, args ...any) workflow.Future {
+	i.buffer.flush(ctx)
+	// Cases log with the levels of the test execution that started them.
+	if cfg, ok := TestLogConfigFromWorkflowContext(ctx); ok {
+		if err := writeLogLevelsHeader(interceptor.WorkflowHeader(ctx), cfg.LogLevels); err != nil {
+			i.GetLogger(ctx).Warn("failed to write log levels header", "error", err)
+		}
+	}
+	return i.Next.ExecuteActivity(ctx, activityType, args...)
+}
+
+func (i *workflowOutboundLogInterceptor) NewTimer(ctx workflow.Context, d time.Duration) workflow.Future {
+	i.buffer.flush(ctx)
+	return i.Next.NewTimer(ctx, d)
+}
+
+func (i *workflowOutboundLogInterceptor) Sleep(ctx workflow.Context, d time.Duration) error {
+	i.buffer.flush(ctx)
+	return i.Next.Sleep(ctx, d)
+}
@@ -0,0 +1,106 @@
+package temporal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+)
+
+// logLevelsHeaderKey is the activity header carrying the log level overrides
+// of the test execution that started the activity.
+const logLevelsHeaderKey = "annex-log-levels"
+
+// LogLevels are the minimum levels of test logs. A nil level allows every log.
+type LogLevels struct {
+	// Local is the minimum level of logs written to the runner logger.
+	Local slog.Leveler
+	// Publish is the minimum level of logs published to Annex.
+	Publish slog.Leveler
+}
+
+// LogLevelOverrides replace the runner log levels for a test execution and the
+// cases it starts. Unlike LogLevels they are fixed values so that they can be
+// carried to activities.
+type LogLevelOverrides struct {
+	Local   *slog.Level
+	Publish *slog.Level
+}
+
+func (o LogLevelOverrides) empty() bool {
+	return o.Local == nil && o.Publish == nil
+}
+
+// Merge returns the overrides with the levels set in other replacing its own.
+func (o LogLevelOverrides) Merge(other LogLevelOverrides) LogLevelOverrides {
+	if other.Local != nil {
+		o.Local = other.Local
+	}
+	if other.Publish != nil {
+		o.Publish = other.Publish
+	}
+	return o
+}
+
+func (l LogLevels) override(o LogLevelOverrides) LogLevels {
+	if o.Local != nil {
+		l.Local = *o.Local
+	}
+	if o.Publish != nil {
+		l.Publish = *o.Publish
+	}
+	return l
+}
+
+func levelEnabled(min slog.Leveler, level Level) bool {
+	return min == nil || level.SlogLevel() >= min.Level()
+}
+
+// ParseLogLevel parses a level name such as "debug" or "WARN", optionally with
+// an offset such as "INFO+2", as accepted by slog.
+func ParseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return level, fmt.Errorf("invalid log level %q: %w", s, err)
+	}
+	return level, nil
+}
+
+type logLevelOverridesKey struct{}
+
+func contextWithLogLevelOverrides(ctx context.Context, overrides LogLevelOverrides) context.Context {
+	return context.WithValue(ctx, logLevelOverridesKey{}, overrides)
+}
+
+func logLevelOverridesFromContext(ctx context.Context) LogLevelOverrides {
+	overrides, _ := getContextVal[LogLevelOverrides](ctx, logLevelOverridesKey{})
+	return overrides
+}
+
+// writeLogLevelsHeader adds the overrides to an activity header. Headers are
+// always encoded with the default converter so that they don't depend on the
+// codecs of the runner.
+func writeLogLevelsHeader(header map[string]*common.Payload, overrides LogLevelOverrides) error {
+	if header == nil || overrides.empty() {
+		return nil
+	}
+	payload, err := converter.GetDefaultDataConverter().ToPayload(overrides)
+	if err != nil {
+		return err
+	}
+	header[logLevelsHeaderKey] = payload
+	return nil
+}
+
+func readLogLevelsHeader(ctx context.Context) (LogLevelOverrides, error) {
+	var overrides LogLevelOverrides
+	payload, ok := interceptor.Header(ctx)[logLevelsHeaderKey]
+	if !ok {
+		return overrides, nil
+	}
+	err := converter.GetDefaultDataConverter().FromPayload(payload, &overrides)
+	return overrides, err
+}
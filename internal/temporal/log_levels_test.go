@@ -0,0 +1,130 @@
+package temporal
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    slog.Level
+		wantErr bool
+	}{
+		{in: "debug", want: slog.LevelDebug},
+		{in: "INFO", want: slog.LevelInfo},
+		{in: "Warn", want: slog.LevelWarn},
+		{in: "error", want: slog.LevelError},
+		{in: "INFO+2", want: slog.LevelInfo + 2},
+		{in: "verbose", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseLogLevel(tt.in)
+			if tt.wantErr {
+				require.ErrorContains(t, err, "invalid log level")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLogLevels_Resolution(t *testing.T) {
+	runnerLevel := &slog.LevelVar{}
+	runnerLevel.Set(slog.LevelWarn)
+	runner := LogLevels{Local: slog.LevelInfo, Publish: runnerLevel}
+
+	tests := []struct {
+		name        string
+		test        LogLevelOverrides
+		execution   LogLevelOverrides
+		level       Level
+		wantLocal   bool
+		wantPublish bool
+	}{
+		{
+			name:        "runner levels",
+			level:       LevelInfo,
+			wantLocal:   true,
+			wantPublish: false,
+		},
+		{
+			name:        "test override",
+			test:        LogLevelOverrides{Publish: levelPtr(slog.LevelDebug)},
+			level:       LevelDebug,
+			wantLocal:   false,
+			wantPublish: true,
+		},
+		{
+			name:        "execution overrides test",
+			test:        LogLevelOverrides{Publish: levelPtr(slog.LevelDebug)},
+			execution:   LogLevelOverrides{Publish: levelPtr(slog.LevelError)},
+			level:       LevelWarn,
+			wantLocal:   true,
+			wantPublish: false,
+		},
+		{
+			name:        "execution keeps test local level",
+			test:        LogLevelOverrides{Local: levelPtr(slog.LevelDebug)},
+			execution:   LogLevelOverrides{Publish: levelPtr(slog.LevelDebug)},
+			level:       LevelDebug,
+			wantLocal:   true,
+			wantPublish: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			levels := runner.override(tt.test.Merge(tt.execution))
+			assert.Equal(t, tt.wantLocal, levelEnabled(levels.Local, tt.level), "local")
+			assert.Equal(t, tt.wantPublish, levelEnabled(levels.Publish, tt.level), "publish")
+		})
+	}
+}
+
+func TestLogLevels_RunnerLevelVar(t *testing.T) {
+	level := &slog.LevelVar{}
+	level.Set(slog.LevelDebug)
+	levels := LogLevels{Publish: level}.override(LogLevelOverrides{})
+
+	assert.True(t, levelEnabled(levels.Publish, LevelDebug))
+	level.Set(slog.LevelError)
+	assert.False(t, levelEnabled(levels.Publish, LevelWarn))
+}
+
+func TestWriteLogLevelsHeader(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides LogLevelOverrides
+		wantKey   bool
+	}{
+		{name: "empty", overrides: LogLevelOverrides{}, wantKey: false},
+		{name: "publish", overrides: LogLevelOverrides{Publish: levelPtr(slog.LevelDebug)}, wantKey: true},
+		{name: "both", overrides: LogLevelOverrides{Local: levelPtr(slog.LevelWarn), Publish: levelPtr(slog.LevelInfo + 2)}, wantKey: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := map[string]*common.Payload{}
+			require.NoError(t, writeLogLevelsHeader(header, tt.overrides))
+
+			payload, ok := header[logLevelsHeaderKey]
+			require.Equal(t, tt.wantKey, ok)
+			if !ok {
+				return
+			}
+			var got LogLevelOverrides
+			require.NoError(t, converter.GetDefaultDataConverter().FromPayload(payload, &got))
+			assert.Equal(t, tt.overrides, got)
+		})
+	}
+}
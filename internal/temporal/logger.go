@@ -2,9 +2,10 @@ package temporal
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"strings"
+	"slices"
 	"time"
 
 	"connectrpc.com/connect"
@@ -12,10 +13,13 @@ import (
 	"github.com/annexsh/annex/log"
 	"github.com/annexsh/annex/test"
 	"github.com/google/uuid"
+	"go.temporal.io/sdk/client"
 	tlog "go.temporal.io/sdk/log"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/annexsh/annex-sdk-go/internal/param"
 )
 
 const logRequestTimeout = 5 * time.Second
@@ -37,6 +41,21 @@ func (l Level) SlogLevel() slog.Level {
 	}
 }
 
+// LevelFromSlog returns the level that logs of an slog level are written at.
+// Levels between the standard slog levels are rounded down.
+func LevelFromSlog(level slog.Level) Level {
+	switch {
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level < slog.LevelWarn:
+		return LevelInfo
+	case level < slog.LevelError:
+		return LevelWarn
+	default:
+		return LevelError
+	}
+}
+
 const (
 	LevelDebug Level = "DEBUG"
 	LevelInfo  Level = "INFO"
@@ -45,8 +64,11 @@ const (
 )
 
 type Logger struct {
-	logger        log.Logger
-	globalKeyvals string
+	logger log.Logger
+	attrs  []slog.Attr
+	// level is the minimum level logged. Every level is logged if nil.
+	level    slog.Leveler
+	redactor *Redactor
 }
 
 func FromLogger(base log.Logger) *Logger {
@@ -55,10 +77,24 @@ func FromLogger(base log.Logger) *Logger {
 	}
 }
 
+// NewRedactingLogger creates a logger that masks sensitive values with
+// redactor before logging.
+func NewRedactingLogger(base log.Logger, redactor *Redactor) *Logger {
+	return &Logger{
+		logger:   base,
+		redactor: redactor,
+	}
+}
+
 func (l *Logger) Debug(msg string, keyvals ...any) {
 	l.log(LevelDebug, msg, keyvals...)
 }
 
+// Enabled reports whether logs of level are logged.
+func (l *Logger) Enabled(level Level) bool {
+	return levelEnabled(l.level, level)
+}
+
 func (l *Logger) Info(msg string, keyvals ...any) {
 	l.log(LevelInfo, msg, keyvals...)
 }
@@ -73,25 +109,51 @@ func (l *Logger) Error(msg string, keyvals ...any) {
 
 // With returns new logger the prepend every log entry with keyvals.
 func (l *Logger) With(keyvals ...any) tlog.Logger {
-	logger := &Logger{
-		logger: l.logger,
-	}
-	if l.globalKeyvals != "" {
-		logger.globalKeyvals = l.globalKeyvals + " "
+	return l.with(keyvals)
+}
+
+func (l *Logger) with(keyvals []any) *Logger {
+	return &Logger{
+		logger:   l.logger,
+		attrs:    l.withAttrs(keyvals),
+		level:    l.level,
+		redactor: l.redactor,
 	}
-	logger.globalKeyvals += strings.TrimSuffix(fmt.Sprintln(keyvals...), "\n")
-	return logger
+}
+
+// withAttrs returns the attributes added with With followed by keyvals.
+func (l *Logger) withAttrs(keyvals []any) []slog.Attr {
+	return slices.Concat(l.attrs, redactAttrs(keyvalsToAttrs(keyvals)))
 }
 
 func (l *Logger) log(level Level, msg string, keyvals ...any) {
-	if l.globalKeyvals == "" {
-		l.logger.Log(context.Background(), level.SlogLevel(), msg, keyvals...)
-	} else {
-		l.logger.Log(context.Background(), level.SlogLevel(), msg, keyvals...)
+	msg, attrs := l.redactor.Redact(msg, l.withAttrs(keyvals))
+	l.logAttrs(level, msg, attrs)
+}
+
+func (l *Logger) logAttrs(level Level, msg string, attrs []slog.Attr) {
+	if !levelEnabled(l.level, level) {
+		return
 	}
+	l.logger.Log(context.Background(), level.SlogLevel(), msg, attrsToArgs(attrs)...)
 }
 
-var _ tlog.Logger = (*TestActivityLogger)(nil)
+// redactAttrs replaces the values of param fields tagged as secret so that
+// they are never logged or published.
+func redactAttrs(attrs []slog.Attr) []slog.Attr {
+	for i, attr := range attrs {
+		if attr.Value.Kind() == slog.KindAny {
+			attrs[i].Value = slog.AnyValue(param.Redact(attr.Value.Any()))
+		}
+	}
+	return attrs
+}
+
+var (
+	_ tlog.WithLogger = (*Logger)(nil)
+	_ tlog.WithLogger = (*TestActivityLogger)(nil)
+	_ tlog.WithLogger = (*TestWorkflowLogger)(nil)
+)
 
 type LogPublisher interface {
 	PublishLog(
@@ -108,19 +170,39 @@ func WithCaseExecID(caseExecID test.CaseExecutionID) CaseOption {
 	}
 }
 
+// WithLogLevels sets the minimum levels of logs written locally and published.
+func WithLogLevels(levels LogLevels) CaseOption {
+	return func(logger *TestActivityLogger) {
+		logger.Logger.level = levels.Local
+		logger.publishLevel = levels.Publish
+	}
+}
+
+// WithRedactor masks sensitive values with redactor before logs are written
+// locally or published.
+func WithRedactor(redactor *Redactor) CaseOption {
+	return func(logger *TestActivityLogger) {
+		logger.Logger.redactor = redactor
+	}
+}
+
 type TestActivityLogger struct {
 	*Logger
-	globalKeyvals string
-	pub           LogPublisher
-	testExecID    test.TestExecutionID
-	caseExecID    *test.CaseExecutionID
+	pub          *BatchPublisher
+	publishLevel slog.Leveler
+	testContext  string
+	testExecID   test.TestExecutionID
+	caseExecID   *test.CaseExecutionID
 }
 
-func NewTestActivityLogger(logger log.Logger, pub LogPublisher, testExecID test.TestExecutionID, opts ...CaseOption) *TestActivityLogger {
+// NewTestActivityLogger creates a logger that publishes to the test execution
+// in the Annex context testContext.
+func NewTestActivityLogger(logger log.Logger, pub *BatchPublisher, testContext string, testExecID test.TestExecutionID, opts ...CaseOption) *TestActivityLogger {
 	activityLogger := &TestActivityLogger{
-		Logger:     FromLogger(logger),
-		pub:        pub,
-		testExecID: testExecID,
+		Logger:      FromLogger(logger),
+		pub:         pub,
+		testContext: testContext,
+		testExecID:  testExecID,
 	}
 	for _, opt := range opts {
 		opt(activityLogger)
@@ -132,6 +214,15 @@ func (l *TestActivityLogger) Debug(msg string, keyvals ...any) {
 	l.log(LevelDebug, msg, keyvals)
 }
 
+// Enabled reports whether logs of level are logged locally or published.
+func (l *TestActivityLogger) Enabled(level Level) bool {
+	return l.Logger.Enabled(level) || l.publishEnabled(level)
+}
+
+func (l *TestActivityLogger) publishEnabled(level Level) bool {
+	return !l.testExecID.Empty() && levelEnabled(l.publishLevel, level)
+}
+
 func (l *TestActivityLogger) Info(msg string, keyvals ...any) {
 	l.log(LevelInfo, msg, keyvals)
 }
@@ -144,139 +235,283 @@ func (l *TestActivityLogger) Error(msg string, keyvals ...any) {
 	l.log(LevelError, msg, keyvals)
 }
 
+// With returns a logger that prepends every log entry with keyvals and still
+// publishes under the same test and case execution.
+func (l *TestActivityLogger) With(keyvals ...any) tlog.Logger {
+	return &TestActivityLogger{
+		Logger:       l.Logger.with(keyvals),
+		pub:          l.pub,
+		publishLevel: l.publishLevel,
+		testContext:  l.testContext,
+		testExecID:   l.testExecID,
+		caseExecID:   l.caseExecID,
+	}
+}
+
 func (l *TestActivityLogger) log(level Level, msg string, keyvals []any) {
-	keyvals = append(keyvals, "test_execution.id", l.testExecID.String())
+	msg, attrs := l.Logger.redactor.Redact(msg, l.Logger.withAttrs(keyvals))
+	localAttrs := append(slices.Clip(attrs), slog.String("test_execution.id", l.testExecID.String()))
 
-	if !l.testExecID.Empty() {
+	if l.publishEnabled(level) {
 		req := &testsv1.PublishLogRequest{
-			Context:         "default",
+			Context:         l.testContext,
 			TestExecutionId: l.testExecID.String(),
 			CaseExecutionId: nil,
 			Level:           string(level),
-			Message:         msg,
+			Message:         formatMessage(msg, renderAttrs(attrs)),
 			CreateTime:      timestamppb.Now(),
 		}
 
 		if l.caseExecID != nil {
 			cid32 := l.caseExecID.Int32()
 			req.CaseExecutionId = &cid32
-			keyvals = append(keyvals, "case_execution.id", l.caseExecID.String())
+			localAttrs = append(localAttrs, slog.String("case_execution.id", l.caseExecID.String()))
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), logRequestTimeout)
-		defer cancel()
-		if _, err := l.pub.PublishLog(ctx, connect.NewRequest(req)); err != nil {
-			kv := append(keyvals, "error", err)
-			l.Logger.Error("failed to publish log", kv...)
-		}
+		// Publishing is asynchronous so that a slow Annex server never stalls
+		// the case.
+		l.pub.Enqueue(req)
 	}
 
-	l.Logger.log(level, msg, keyvals...)
+	l.Logger.logAttrs(level, msg, localAttrs)
 }
 
 type TestLogRequest struct {
+	Context         string
 	Level           Level
 	Message         string
-	KeyVals         []any
-	GlobalKeyVals   string
+	Attrs           []Attr
 	TestExecutionID test.TestExecutionID
+	CreateTime      time.Time
+	// PublishLevel overrides the minimum level of published logs set on the
+	// activity.
+	PublishLevel *slog.Level
+}
+
+type TestLogBatchRequest struct {
+	Logs []TestLogRequest
 }
 
-type TestLogResponse struct {
-	LogID uuid.UUID
+type TestLogBatchResponse struct {
+	LogIDs []uuid.UUID
 }
 
 // TestLogActivity is used to publish test logs from a workflow. This must be
 // executed as a local activity to minimize the number of workflow events.
 type TestLogActivity struct {
-	pub LogPublisher
+	pub          LogPublisher
+	publishLevel slog.Leveler
+	metrics      client.MetricsHandler
 }
 
-func NewTestLogActivity(pub LogPublisher) *TestLogActivity {
+// NewTestLogActivity creates the workflow log activity. Logs below
+// publishLevel are not published unless the request overrides it. Logs that
+// fail to publish are counted with metrics.
+func NewTestLogActivity(pub LogPublisher, publishLevel slog.Leveler, metrics client.MetricsHandler) *TestLogActivity {
+	if metrics == nil {
+		metrics = client.MetricsNopHandler
+	}
 	return &TestLogActivity{
-		pub: pub,
+		pub:          pub,
+		publishLevel: publishLevel,
+		metrics:      metrics,
+	}
+}
+
+// PublishBatch publishes a batch of buffered workflow logs one at a time so
+// that they reach Annex in order, stopping at the first log that fails to
+// publish. Logs are filtered by level here rather than in the workflow so that
+// changing the runner level never changes the workflow history on replay. The
+// ids of unpublished logs are uuid.Nil.
+func (t *TestLogActivity) PublishBatch(ctx context.Context, req TestLogBatchRequest) (*TestLogBatchResponse, error) {
+	res := &TestLogBatchResponse{
+		LogIDs: make([]uuid.UUID, len(req.Logs)),
+	}
+	for i, entry := range req.Logs {
+		var err error
+		if res.LogIDs[i], err = t.publish(ctx, entry); err != nil {
+			countDropped(t.metrics, dropReasonFailed, int64(len(req.Logs)-i))
+			return nil, fmt.Errorf("%d of %d logs not published: %w", len(req.Logs)-i, len(req.Logs), err)
+		}
 	}
+	// Return log ids so that they are saved in workflow history
+	return res, nil
 }
 
-func (t *TestLogActivity) Publish(ctx context.Context, req TestLogRequest) (*TestLogResponse, error) {
+func (t *TestLogActivity) publish(ctx context.Context, req TestLogRequest) (uuid.UUID, error) {
+	publishLevel := t.publishLevel
+	if req.PublishLevel != nil {
+		publishLevel = *req.PublishLevel
+	}
+	if !levelEnabled(publishLevel, req.Level) {
+		return uuid.Nil, nil
+	}
+
 	ctx = ContextWithTestLogConfig(ctx, TestLogConfig{
+		Context:    req.Context,
 		TestExecID: req.TestExecutionID,
 	})
 
-	keyVals := req.GlobalKeyVals + strings.TrimSuffix(fmt.Sprintln(req.KeyVals...), "\n")
-
 	pubReq := &testsv1.PublishLogRequest{
-		Context:         "default",
+		Context:         req.Context,
 		TestExecutionId: req.TestExecutionID.String(),
 		Level:           string(req.Level),
-		Message:         req.Message + " " + keyVals,
-		CreateTime:      timestamppb.New(time.Now().UTC()),
+		Message:         formatMessage(req.Message, req.Attrs),
+		CreateTime:      timestamppb.New(req.CreateTime.UTC()),
 	}
 
+	ctx, cancel := context.WithTimeout(ctx, logRequestTimeout)
+	defer cancel()
 	res, err := t.pub.PublishLog(ctx, connect.NewRequest(pubReq))
+	if errors.Is(err, ErrLogSpooled) {
+		// Published from the spool, so the log has no id yet.
+		return uuid.Nil, nil
+	}
 	if err != nil {
-		return nil, err
+		return uuid.Nil, err
 	}
+	return uuid.Parse(res.Msg.LogId)
+}
 
-	logID, err := uuid.Parse(res.Msg.LogId)
-	if err != nil {
-		return nil, err
+// maxWorkflowLogBuffer is the number of buffered workflow logs that triggers
+// a flush.
+const maxWorkflowLogBuffer = 100
+
+// workflowLogBuffer holds the logs of a workflow execution until they are
+// published in a batch. Flushes only happen at deterministic points, so on
+// replay every flush matches a recorded local activity and replayed logs are
+// never republished.
+type workflowLogBuffer struct {
+	logger *Logger
+	logs   []TestLogRequest
+}
+
+func newWorkflowLogBuffer(logger log.Logger) *workflowLogBuffer {
+	return &workflowLogBuffer{
+		logger: FromLogger(logger),
 	}
+}
 
-	// Return log id so that it is saved in workflow history
-	return &TestLogResponse{
-		LogID: logID,
-	}, nil
+func (b *workflowLogBuffer) add(ctx workflow.Context, req TestLogRequest) {
+	b.logs = append(b.logs, req)
+	if len(b.logs) >= maxWorkflowLogBuffer {
+		b.flush(ctx)
+	}
 }
 
+func (b *workflowLogBuffer) flush(ctx workflow.Context) {
+	if len(b.logs) == 0 {
+		return
+	}
+	req := TestLogBatchRequest{Logs: b.logs}
+	b.logs = nil
+
+	ctx = workflow.WithLocalActivityOptions(ctx, workflow.LocalActivityOptions{
+		StartToCloseTimeout: workflowLogFlushTimeout,
+		// no retries since operation is non-critical
+		RetryPolicy: &temporal.RetryPolicy{
+			MaximumAttempts: 1,
+		},
+	})
+	var logActivity TestLogActivity
+	if err := workflow.ExecuteLocalActivity(ctx, logActivity.PublishBatch, req).Get(ctx, nil); err != nil {
+		if !workflow.IsReplaying(ctx) {
+			b.logger.Warn("failed to publish workflow logs", "count", len(req.Logs), "error", err)
+		}
+	}
+}
+
+// workflowLogFlushTimeout bounds how long a workflow waits on a flush, since
+// the workflow is blocked until it completes. Logs that are not published in
+// time are dropped rather than failing the workflow.
+const workflowLogFlushTimeout = 2 * logRequestTimeout
+
 type TestWorkflowLogger struct {
 	*Logger
-	ctx        workflow.Context
-	testExecID test.TestExecutionID
+	ctx          workflow.Context
+	buffer       *workflowLogBuffer
+	publishLevel *slog.Level
+	testContext  string
+	testExecID   test.TestExecutionID
 }
 
-func NewTestWorkflowLogger(ctx workflow.Context, logger log.Logger, testExecID test.TestExecutionID) *TestWorkflowLogger {
-	return &TestWorkflowLogger{
-		Logger: FromLogger(logger),
-		ctx: workflow.WithLocalActivityOptions(ctx, workflow.LocalActivityOptions{
-			// local activity requires short start to close timeout
-			StartToCloseTimeout: logRequestTimeout + (100 * time.Millisecond),
-			// no retries since operation is non-critical
-			RetryPolicy: &temporal.RetryPolicy{
-				MaximumAttempts: 1,
-			},
-		}),
-		testExecID: testExecID,
+// newTestWorkflowLogger creates a workflow logger that logs locally from
+// localLevel. Logs are published from publishLevel, or from the level of the
+// log activity if nil. Sensitive values are masked with redactor.
+func newTestWorkflowLogger(ctx workflow.Context, logger log.Logger, redactor *Redactor, buffer *workflowLogBuffer, localLevel slog.Leveler, publishLevel *slog.Level, testContext string, testExecID test.TestExecutionID) *TestWorkflowLogger {
+	wfLogger := &TestWorkflowLogger{
+		Logger:       NewRedactingLogger(logger, redactor),
+		ctx:          ctx,
+		buffer:       buffer,
+		publishLevel: publishLevel,
+		testContext:  testContext,
+		testExecID:   testExecID,
 	}
+	wfLogger.Logger.level = localLevel
+	return wfLogger
 }
 
 func (l *TestWorkflowLogger) Debug(msg string, keyvals ...any) {
 	l.log(LevelDebug, msg, keyvals...)
 }
 
+// Enabled reports whether logs of level are logged locally or may be
+// published. Only the publish level override of the test execution decides
+// whether a log is buffered, since it is the same on replay; logs above the
+// override are filtered by the runner publish level in the log activity.
+func (l *TestWorkflowLogger) Enabled(level Level) bool {
+	return l.Logger.Enabled(level) || l.publishEnabled(level)
+}
+
+func (l *TestWorkflowLogger) publishEnabled(level Level) bool {
+	return l.publishLevel == nil || levelEnabled(*l.publishLevel, level)
+}
+
 func (l *TestWorkflowLogger) Info(msg string, keyvals ...any) {
-	l.log(LevelInfo, msg, keyvals)
+	l.log(LevelInfo, msg, keyvals...)
 }
 
 func (l *TestWorkflowLogger) Warn(msg string, keyvals ...any) {
-	l.log(LevelWarn, msg, keyvals)
+	l.log(LevelWarn, msg, keyvals...)
 }
 
 func (l *TestWorkflowLogger) Error(msg string, keyvals ...any) {
-	l.log(LevelError, msg, keyvals)
+	l.log(LevelError, msg, keyvals...)
+}
+
+// With returns a logger that prepends every log entry with keyvals and still
+// publishes under the same test execution.
+func (l *TestWorkflowLogger) With(keyvals ...any) tlog.Logger {
+	return &TestWorkflowLogger{
+		Logger:       l.Logger.with(keyvals),
+		ctx:          l.ctx,
+		buffer:       l.buffer,
+		publishLevel: l.publishLevel,
+		testContext:  l.testContext,
+		testExecID:   l.testExecID,
+	}
 }
 
 func (l *TestWorkflowLogger) log(level Level, msg string, keyvals ...any) {
-	keyvals = append(keyvals, "test_execution.id", l.testExecID.String())
-	request := TestLogRequest{
-		Level:           level,
-		Message:         msg,
-		KeyVals:         keyvals,
-		TestExecutionID: l.testExecID,
+	msg, attrs := l.Logger.redactor.Redact(msg, l.Logger.withAttrs(keyvals))
+
+	// Logs are buffered and published when the workflow reaches a case, a
+	// timer or completion rather than with a local activity per log.
+	// Attributes are rendered now since their types are lost when the
+	// request is encoded.
+	if l.publishEnabled(level) {
+		l.buffer.add(l.ctx, TestLogRequest{
+			Context:         l.testContext,
+			Level:           level,
+			Message:         msg,
+			Attrs:           renderAttrs(attrs),
+			TestExecutionID: l.testExecID,
+			CreateTime:      workflow.Now(l.ctx),
+			PublishLevel:    l.publishLevel,
+		})
 	}
-	var logActivity TestLogActivity
-	if err := workflow.ExecuteLocalActivity(l.ctx, logActivity.Publish, request).Get(l.ctx, nil); err != nil {
-		keyvals = append(keyvals, "error", err)
-		l.Logger.Error("failed to execute logger local activity", keyvals...)
+
+	if !workflow.IsReplaying(l.ctx) {
+		l.Logger.logAttrs(level, msg, append(attrs, slog.String("test_execution.id", l.testExecID.String())))
 	}
 }
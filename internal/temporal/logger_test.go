@@ -0,0 +1,217 @@
+package temporal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/annexsh/annex/log"
+	"github.com/annexsh/annex/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func levelPtr(level slog.Level) *slog.Level {
+	return &level
+}
+
+func TestLevelFromSlog(t *testing.T) {
+	tests := []struct {
+		level slog.Level
+		want  Level
+	}{
+		{level: slog.LevelDebug - 4, want: LevelDebug},
+		{level: slog.LevelDebug, want: LevelDebug},
+		{level: slog.LevelInfo, want: LevelInfo},
+		{level: slog.LevelInfo + 2, want: LevelInfo},
+		{level: slog.LevelWarn, want: LevelWarn},
+		{level: slog.LevelError, want: LevelError},
+		{level: slog.LevelError + 8, want: LevelError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			assert.Equal(t, tt.want, LevelFromSlog(tt.level))
+		})
+	}
+}
+
+func TestTestActivityLogger_Enabled(t *testing.T) {
+	tests := []struct {
+		name       string
+		levels     LogLevels
+		testExecID test.TestExecutionID
+		level      Level
+		want       bool
+	}{
+		{name: "no levels", testExecID: test.NewTestExecutionID(), level: LevelDebug, want: true},
+		{
+			name:       "below both levels",
+			levels:     LogLevels{Local: slog.LevelInfo, Publish: slog.LevelWarn},
+			testExecID: test.NewTestExecutionID(),
+			level:      LevelDebug,
+			want:       false,
+		},
+		{
+			name:       "local only",
+			levels:     LogLevels{Local: slog.LevelDebug, Publish: slog.LevelWarn},
+			testExecID: test.NewTestExecutionID(),
+			level:      LevelInfo,
+			want:       true,
+		},
+		{
+			name:       "publish only",
+			levels:     LogLevels{Local: slog.LevelError, Publish: slog.LevelInfo},
+			testExecID: test.NewTestExecutionID(),
+			level:      LevelInfo,
+			want:       true,
+		},
+		{
+			name:   "publish without test execution",
+			levels: LogLevels{Local: slog.LevelError, Publish: slog.LevelInfo},
+			level:  LevelInfo,
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := NewTestActivityLogger(log.NewNopLogger(), nil, "default", tt.testExecID, WithLogLevels(tt.levels))
+			assert.Equal(t, tt.want, logger.Enabled(tt.level))
+			assert.Equal(t, tt.want, logger.With("key", "value").(*TestActivityLogger).Enabled(tt.level))
+		})
+	}
+}
+
+func TestTestWorkflowLogger_Enabled(t *testing.T) {
+	tests := []struct {
+		name         string
+		localLevel   slog.Leveler
+		publishLevel *slog.Level
+		level        Level
+		want         bool
+	}{
+		{
+			name:       "published without override",
+			localLevel: slog.LevelError,
+			level:      LevelDebug,
+			want:       true,
+		},
+		{
+			name:         "below override and local level",
+			localLevel:   slog.LevelError,
+			publishLevel: levelPtr(slog.LevelWarn),
+			level:        LevelInfo,
+			want:         false,
+		},
+		{
+			name:         "at override",
+			localLevel:   slog.LevelError,
+			publishLevel: levelPtr(slog.LevelWarn),
+			level:        LevelWarn,
+			want:         true,
+		},
+		{
+			name:         "local only",
+			localLevel:   slog.LevelDebug,
+			publishLevel: levelPtr(slog.LevelWarn),
+			level:        LevelInfo,
+			want:         true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := newTestWorkflowLogger(nil, log.NewNopLogger(), nil, nil, tt.localLevel, tt.publishLevel, "default", test.NewTestExecutionID())
+			assert.Equal(t, tt.want, logger.Enabled(tt.level))
+		})
+	}
+}
+
+func TestTestLogActivity_PublishBatch(t *testing.T) {
+	testExecID := test.NewTestExecutionID()
+	newBatch := func(levels ...Level) TestLogBatchRequest {
+		var req TestLogBatchRequest
+		for i, level := range levels {
+			req.Logs = append(req.Logs, TestLogRequest{
+				Level:           level,
+				Message:         fmt.Sprint(i),
+				TestExecutionID: testExecID,
+				CreateTime:      time.Now(),
+			})
+		}
+		return req
+	}
+
+	tests := []struct {
+		name          string
+		publishLevel  slog.Leveler
+		req           TestLogBatchRequest
+		override      *slog.Level
+		wantPublished []string
+	}{
+		{
+			name:          "in order",
+			req:           newBatch(LevelInfo, LevelDebug, LevelWarn, LevelError, LevelInfo, LevelInfo),
+			wantPublished: []string{"0", "1", "2", "3", "4", "5"},
+		},
+		{
+			name:          "runner level",
+			publishLevel:  slog.LevelWarn,
+			req:           newBatch(LevelInfo, LevelWarn, LevelDebug, LevelError),
+			wantPublished: []string{"1", "3"},
+		},
+		{
+			name:          "override",
+			publishLevel:  slog.LevelWarn,
+			override:      levelPtr(slog.LevelDebug),
+			req:           newBatch(LevelInfo, LevelDebug),
+			wantPublished: []string{"0", "1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := range tt.req.Logs {
+				tt.req.Logs[i].PublishLevel = tt.override
+			}
+			pub := &fakePublisher{delay: func() time.Duration {
+				return time.Duration(rand.IntN(200)) * time.Microsecond
+			}}
+
+			res, err := NewTestLogActivity(pub, tt.publishLevel, nil).PublishBatch(context.Background(), tt.req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPublished, pub.messages())
+
+			published := 0
+			for _, id := range res.LogIDs {
+				if id != uuid.Nil {
+					published++
+				}
+			}
+			assert.Equal(t, len(tt.wantPublished), published)
+		})
+	}
+}
+
+func TestTestLogActivity_PublishBatchStopsAtFirstError(t *testing.T) {
+	pub := &fakePublisher{}
+	pub.setErr(connect.NewError(connect.CodeInvalidArgument, errors.New("invalid")))
+	metrics := newFakeMetrics()
+
+	_, err := NewTestLogActivity(pub, nil, metrics).PublishBatch(context.Background(), TestLogBatchRequest{
+		Logs: []TestLogRequest{
+			{Level: LevelInfo, Message: "a", TestExecutionID: test.NewTestExecutionID()},
+			{Level: LevelInfo, Message: "b", TestExecutionID: test.NewTestExecutionID()},
+		},
+	})
+	require.ErrorContains(t, err, "2 of 2 logs not published")
+	assert.Equal(t, int64(2), metrics.dropped(dropReasonFailed))
+}
@@ -0,0 +1,242 @@
+package temporal
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/annexsh/annex-proto/go/gen/annex/tests/v1"
+	"github.com/annexsh/annex/log"
+	"go.temporal.io/sdk/client"
+)
+
+const (
+	defaultLogQueueSize     = 10000
+	defaultLogBatchSize     = 100
+	defaultLogFlushInterval = time.Second
+	// logPublishConcurrency is the number of test executions whose logs in a
+	// batch are published at once since Annex does not have a batch publish
+	// endpoint.
+	logPublishConcurrency = 8
+)
+
+type BatchPublisherOption func(p *BatchPublisher)
+
+// WithLogQueueSize sets the maximum number of logs waiting to be published.
+// Logs are dropped while the queue is full.
+func WithLogQueueSize(size int) BatchPublisherOption {
+	return func(p *BatchPublisher) {
+		p.queue = make(chan logEntry, size)
+	}
+}
+
+// WithLogBatchSize sets the number of queued logs that triggers a flush.
+func WithLogBatchSize(size int) BatchPublisherOption {
+	return func(p *BatchPublisher) {
+		p.batchSize = size
+	}
+}
+
+// WithLogFlushInterval sets the maximum time a log waits in the queue.
+func WithLogFlushInterval(interval time.Duration) BatchPublisherOption {
+	return func(p *BatchPublisher) {
+		p.flushInterval = interval
+	}
+}
+
+// WithLogMetricsHandler sets the handler that dropped logs are counted with.
+func WithLogMetricsHandler(metrics client.MetricsHandler) BatchPublisherOption {
+	return func(p *BatchPublisher) {
+		p.metrics = metrics
+	}
+}
+
+type logEntry struct {
+	req   *testsv1.PublishLogRequest
+	flush chan struct{} // set for flush markers
+}
+
+// BatchPublisher publishes logs in the background so that logging never blocks
+// the caller on the Annex server. Logs are queued and published in batches
+// when the batch size or flush interval is reached or when flushed. The logs of
+// a test execution are published one at a time in the order they were queued;
+// only logs of different test executions are published concurrently.
+type BatchPublisher struct {
+	pub           LogPublisher
+	logger        log.Logger
+	metrics       client.MetricsHandler
+	queue         chan logEntry
+	batchSize     int
+	flushInterval time.Duration
+	dropped       atomic.Int64
+	mu            sync.RWMutex // guards sends to queue against Close
+	closed        bool
+	done          chan struct{}
+}
+
+func NewBatchPublisher(pub LogPublisher, logger log.Logger, opts ...BatchPublisherOption) *BatchPublisher {
+	p := &BatchPublisher{
+		pub:           pub,
+		logger:        logger,
+		metrics:       client.MetricsNopHandler,
+		queue:         make(chan logEntry, defaultLogQueueSize),
+		batchSize:     defaultLogBatchSize,
+		flushInterval: defaultLogFlushInterval,
+		done:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	go p.run()
+	return p
+}
+
+// ErrLogPublisherClosed is returned when flushing a publisher that has been
+// closed.
+var ErrLogPublisherClosed = errors.New("log publisher closed")
+
+// Enqueue queues a log to be published. It never blocks: the log is dropped if
+// the queue is full or the publisher is closed.
+func (p *BatchPublisher) Enqueue(req *testsv1.PublishLogRequest) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		countDropped(p.metrics, dropReasonClosed, 1)
+		return
+	}
+	select {
+	case p.queue <- logEntry{req: req}:
+	default:
+		p.dropped.Add(1)
+	}
+}
+
+// Flush waits until every log queued before the call has been published or
+// ctx is done.
+func (p *BatchPublisher) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	if err := p.send(ctx, logEntry{flush: flushed}); err != nil {
+		return err
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *BatchPublisher) send(ctx context.Context, entry logEntry) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrLogPublisherClosed
+	}
+	select {
+	case p.queue <- entry:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close publishes the queued logs and stops the publisher. Logs enqueued after
+// Close are dropped.
+func (p *BatchPublisher) Close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	<-p.done
+}
+
+func (p *BatchPublisher) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.flushInterval)
+	defer ticker.Stop()
+
+	var batch []*testsv1.PublishLogRequest
+	for {
+		select {
+		case entry, ok := <-p.queue:
+			if !ok {
+				p.publish(batch)
+				return
+			}
+			if entry.flush != nil {
+				p.publish(batch)
+				batch = nil
+				close(entry.flush)
+				continue
+			}
+			batch = append(batch, entry.req)
+			if len(batch) >= p.batchSize {
+				p.publish(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			p.publish(batch)
+			batch = nil
+		}
+	}
+}
+
+func (p *BatchPublisher) publish(batch []*testsv1.PublishLogRequest) {
+	if dropped := p.dropped.Swap(0); dropped > 0 {
+		p.logger.Warn("dropped test logs: publish queue full", "count", dropped)
+		countDropped(p.metrics, dropReasonQueue, dropped)
+	}
+	if len(batch) == 0 {
+		return
+	}
+
+	sem := make(chan struct{}, logPublishConcurrency)
+	var wg sync.WaitGroup
+	for _, reqs := range groupByTestExecution(batch) {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			for _, req := range reqs {
+				p.publishLog(req)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (p *BatchPublisher) publishLog(req *testsv1.PublishLogRequest) {
+	ctx, cancel := context.WithTimeout(context.Background(), logRequestTimeout)
+	defer cancel()
+	// Spooled logs are published once Annex is reachable again.
+	if _, err := p.pub.PublishLog(ctx, connect.NewRequest(req)); err != nil && !errors.Is(err, ErrLogSpooled) {
+		p.logger.Error("failed to publish log", "test_execution.id", req.TestExecutionId, "error", err)
+		countDropped(p.metrics, dropReasonFailed, 1)
+	}
+}
+
+// groupByTestExecution splits a batch into the logs of each test execution,
+// keeping their order.
+func groupByTestExecution(batch []*testsv1.PublishLogRequest) [][]*testsv1.PublishLogRequest {
+	var groups [][]*testsv1.PublishLogRequest
+	index := map[string]int{}
+	for _, req := range batch {
+		i, ok := index[req.TestExecutionId]
+		if !ok {
+			i = len(groups)
+			index[req.TestExecutionId] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], req)
+	}
+	return groups
+}
@@ -0,0 +1,302 @@
+package temporal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/annexsh/annex-proto/go/gen/annex/tests/v1"
+	"github.com/annexsh/annex/log"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/client"
+)
+
+// fakePublisher records published logs. Logs are rejected with err while it
+// is set.
+type fakePublisher struct {
+	mu        sync.Mutex
+	published []*testsv1.PublishLogRequest
+	err       error
+	delay     func() time.Duration
+	block     chan struct{}
+}
+
+func (p *fakePublisher) PublishLog(
+	_ context.Context,
+	req *connect.Request[testsv1.PublishLogRequest],
+) (*connect.Response[testsv1.PublishLogResponse], error) {
+	if p.block != nil {
+		<-p.block
+	}
+	if p.delay != nil {
+		time.Sleep(p.delay())
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return nil, p.err
+	}
+	p.published = append(p.published, req.Msg)
+	return connect.NewResponse(&testsv1.PublishLogResponse{LogId: uuid.NewString()}), nil
+}
+
+func (p *fakePublisher) setErr(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+func (p *fakePublisher) messages() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	msgs := make([]string, len(p.published))
+	for i, req := range p.published {
+		msgs[i] = req.Message
+	}
+	return msgs
+}
+
+// fakeMetrics counts counter increments by name and tags.
+type fakeMetrics struct {
+	mu     *sync.Mutex
+	counts map[string]int64
+	tags   string
+}
+
+func newFakeMetrics() *fakeMetrics {
+	return &fakeMetrics{mu: &sync.Mutex{}, counts: map[string]int64{}}
+}
+
+func (m *fakeMetrics) WithTags(tags map[string]string) client.MetricsHandler {
+	return &fakeMetrics{mu: m.mu, counts: m.counts, tags: fmt.Sprint(tags)}
+}
+
+func (m *fakeMetrics) Counter(name string) client.MetricsCounter {
+	return fakeCounter{metrics: m, key: name + m.tags}
+}
+
+func (m *fakeMetrics) Gauge(string) client.MetricsGauge {
+	return client.MetricsNopHandler.Gauge("")
+}
+
+func (m *fakeMetrics) Timer(string) client.MetricsTimer {
+	return client.MetricsNopHandler.Timer("")
+}
+
+func (m *fakeMetrics) dropped(reason string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counts[droppedLogsMetric+fmt.Sprint(map[string]string{"reason": reason})]
+}
+
+type fakeCounter struct {
+	metrics *fakeMetrics
+	key     string
+}
+
+func (c fakeCounter) Inc(n int64) {
+	c.metrics.mu.Lock()
+	defer c.metrics.mu.Unlock()
+	c.metrics.counts[c.key] += n
+}
+
+func newLogRequest(testExecID string, msg string) *testsv1.PublishLogRequest {
+	return &testsv1.PublishLogRequest{
+		TestExecutionId: testExecID,
+		Level:           "INFO",
+		Message:         msg,
+	}
+}
+
+func TestBatchPublisher_PublishesInOrderPerTestExecution(t *testing.T) {
+	tests := []struct {
+		name      string
+		batchSize int
+		execs     int
+		logs      int
+	}{
+		{name: "single execution", batchSize: 10, execs: 1, logs: 50},
+		{name: "many executions", batchSize: 25, execs: 6, logs: 40},
+		{name: "batch per log", batchSize: 1, execs: 3, logs: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub := &fakePublisher{delay: func() time.Duration {
+				return time.Duration(rand.IntN(200)) * time.Microsecond
+			}}
+			p := NewBatchPublisher(pub, log.NewNopLogger(), WithLogBatchSize(tt.batchSize))
+
+			for i := range tt.logs {
+				for exec := range tt.execs {
+					p.Enqueue(newLogRequest(fmt.Sprint(exec), fmt.Sprint(i)))
+				}
+			}
+			p.Close()
+
+			got := map[string][]string{}
+			pub.mu.Lock()
+			for _, req := range pub.published {
+				got[req.TestExecutionId] = append(got[req.TestExecutionId], req.Message)
+			}
+			pub.mu.Unlock()
+
+			require.Len(t, got, tt.execs)
+			for exec, msgs := range got {
+				require.Len(t, msgs, tt.logs, "test execution %s", exec)
+				for i, msg := range msgs {
+					assert.Equal(t, fmt.Sprint(i), msg, "test execution %s", exec)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchPublisher_Flush(t *testing.T) {
+	pub := &fakePublisher{}
+	p := NewBatchPublisher(pub, log.NewNopLogger(), WithLogFlushInterval(time.Hour))
+	defer p.Close()
+
+	p.Enqueue(newLogRequest("exec", "first"))
+	p.Enqueue(newLogRequest("exec", "second"))
+	require.NoError(t, p.Flush(context.Background()))
+	assert.Equal(t, []string{"first", "second"}, pub.messages())
+}
+
+func TestBatchPublisher_FlushInterval(t *testing.T) {
+	pub := &fakePublisher{}
+	p := NewBatchPublisher(pub, log.NewNopLogger(), WithLogFlushInterval(10*time.Millisecond))
+	defer p.Close()
+
+	p.Enqueue(newLogRequest("exec", "log"))
+	assert.Eventually(t, func() bool {
+		return len(pub.messages()) == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestBatchPublisher_DropsWhenQueueFull(t *testing.T) {
+	pub := &fakePublisher{block: make(chan struct{})}
+	metrics := newFakeMetrics()
+	p := NewBatchPublisher(pub, log.NewNopLogger(),
+		WithLogQueueSize(1),
+		WithLogBatchSize(1),
+		WithLogMetricsHandler(metrics),
+	)
+
+	// The first log is taken from the queue and blocks publishing, the second
+	// fills the queue and the rest are dropped.
+	p.Enqueue(newLogRequest("exec", "published"))
+	require.Eventually(t, func() bool {
+		return len(p.queue) == 0
+	}, time.Second, time.Millisecond)
+	p.Enqueue(newLogRequest("exec", "queued"))
+	for range 3 {
+		p.Enqueue(newLogRequest("exec", "dropped"))
+	}
+
+	close(pub.block)
+	p.Close()
+
+	assert.Equal(t, []string{"published", "queued"}, pub.messages())
+	assert.Equal(t, int64(3), metrics.dropped(dropReasonQueue))
+}
+
+func TestBatchPublisher_CountsFailedPublishes(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantDropped int64
+	}{
+		{
+			name:        "rejected",
+			err:         connect.NewError(connect.CodeInvalidArgument, errors.New("bad log")),
+			wantDropped: 2,
+		},
+		{
+			name:        "unavailable",
+			err:         connect.NewError(connect.CodeUnavailable, errors.New("down")),
+			wantDropped: 2,
+		},
+		{
+			name: "spooled",
+			err:  fmt.Errorf("%w: down", ErrLogSpooled),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := newFakeMetrics()
+			p := NewBatchPublisher(&fakePublisher{err: tt.err}, log.NewNopLogger(), WithLogMetricsHandler(metrics))
+
+			p.Enqueue(newLogRequest("exec", "1"))
+			p.Enqueue(newLogRequest("exec", "2"))
+			p.Close()
+
+			assert.Equal(t, tt.wantDropped, metrics.dropped(dropReasonFailed))
+		})
+	}
+}
+
+func TestBatchPublisher_Closed(t *testing.T) {
+	pub := &fakePublisher{}
+	metrics := newFakeMetrics()
+	p := NewBatchPublisher(pub, log.NewNopLogger(), WithLogMetricsHandler(metrics))
+
+	p.Enqueue(newLogRequest("exec", "published"))
+	p.Close()
+	p.Close()
+
+	assert.NotPanics(t, func() {
+		p.Enqueue(newLogRequest("exec", "dropped"))
+	})
+	assert.ErrorIs(t, p.Flush(context.Background()), ErrLogPublisherClosed)
+	assert.Equal(t, []string{"published"}, pub.messages())
+	assert.Equal(t, int64(1), metrics.dropped(dropReasonClosed))
+}
+
+func TestBatchPublisher_CloseDuringFlush(t *testing.T) {
+	pub := &fakePublisher{}
+	p := NewBatchPublisher(pub, log.NewNopLogger(), WithLogFlushInterval(time.Hour))
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Enqueue(newLogRequest("exec", "log"))
+			err := p.Flush(context.Background())
+			if err != nil {
+				assert.ErrorIs(t, err, ErrLogPublisherClosed)
+			}
+		}()
+	}
+	p.Close()
+	wg.Wait()
+}
+
+func TestGroupByTestExecution(t *testing.T) {
+	batch := []*testsv1.PublishLogRequest{
+		newLogRequest("a", "1"),
+		newLogRequest("b", "1"),
+		newLogRequest("a", "2"),
+		newLogRequest("c", "1"),
+		newLogRequest("b", "2"),
+	}
+
+	var got [][]string
+	for _, group := range groupByTestExecution(batch) {
+		var msgs []string
+		for _, req := range group {
+			msgs = append(msgs, req.TestExecutionId+req.Message)
+		}
+		got = append(got, msgs)
+	}
+	assert.Equal(t, [][]string{{"a1", "a2"}, {"b1", "b2"}, {"c1"}}, got)
+}
@@ -0,0 +1,280 @@
+package temporal
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/annexsh/annex-sdk-go/internal/param"
+)
+
+const (
+	// minSecretLength is the length below which registered secret values are
+	// ignored, since masking them would mask unrelated text.
+	minSecretLength = 4
+	// maxSecrets is the number of registered secret values that are masked.
+	// The least recently registered values are forgotten first.
+	maxSecrets = 1024
+	// minCredentialLength is the minimum length of a bearer or basic
+	// credential.
+	minCredentialLength = 8
+)
+
+// DefaultRedactKeys are the attribute keys whose values are always redacted.
+var DefaultRedactKeys = []string{
+	"authorization",
+	"proxy-authorization",
+	"cookie",
+	"set-cookie",
+	"x-api-key",
+	"api-key",
+	"apikey",
+	"password",
+	"passwd",
+	"secret",
+	"client-secret",
+	"token",
+	"access-token",
+	"refresh-token",
+}
+
+// DefaultRedactPatterns match credentials that are always redacted from log
+// messages and values.
+var DefaultRedactPatterns = []*regexp.Regexp{
+	authSchemePattern("bearer", "basic"),
+}
+
+// authSchemePattern matches an HTTP authorization scheme followed by a
+// credential. The credential must be a token68 value of at least 8 characters
+// containing a digit, "+" or "/", so that text such as "running basic checks"
+// or "basic authentication" is not masked.
+func authSchemePattern(schemes ...string) *regexp.Regexp {
+	const (
+		token  = `[a-z0-9\-._~+/]`
+		plain  = `[a-z\-._~]`
+		marker = `[0-9+/]`
+	)
+	// Each alternative matches a credential whose first marker is at a given
+	// index, so that the credential is at least minCredentialLength long.
+	alts := []string{fmt.Sprintf(`%s{%d,}%s%s*`, plain, minCredentialLength-1, marker, token)}
+	for i := 0; i < minCredentialLength-1; i++ {
+		alts = append(alts, fmt.Sprintf(`%s{%d}%s%s{%d,}`, plain, i, marker, token, minCredentialLength-1-i))
+	}
+	return regexp.MustCompile(`(?i)\b(?:` + strings.Join(schemes, "|") + `)\s+(?:` + strings.Join(alts, "|") + `)=*`)
+}
+
+// Redactor masks sensitive values in logs before they are written locally or
+// published. Values are masked if their attribute key is a redacted key, and
+// registered secret values and matches of the patterns are masked anywhere in
+// messages and values. Values of http.Header, map[string]string and
+// map[string][]string attributes are masked if their map key is a redacted
+// key. A nil Redactor does not redact anything.
+type Redactor struct {
+	patterns []*regexp.Regexp
+	keys     map[string]bool
+
+	mu sync.RWMutex
+	// secrets are ordered from the least to the most recently registered.
+	secrets []string
+	// permanentSecrets are never forgotten, unlike secrets.
+	permanentSecrets []string
+	replacer         *strings.Replacer
+	// stale is set when the replacer must be rebuilt from secrets.
+	stale bool
+}
+
+// NewRedactor creates a redactor for the attribute keys and patterns. Keys are
+// matched case-insensitively against the full key and its last dot separated
+// segment, with "-" and "_" treated as equal.
+func NewRedactor(keys []string, patterns []*regexp.Regexp) *Redactor {
+	r := &Redactor{
+		patterns: patterns,
+		keys:     make(map[string]bool, len(keys)),
+	}
+	for _, key := range keys {
+		r.keys[normalizeRedactKey(key)] = true
+	}
+	return r
+}
+
+// AddSecrets registers secret values that are masked wherever they appear.
+// Values shorter than 4 characters are ignored. Only the 1024 most recently
+// registered values are masked.
+func (r *Redactor) AddSecrets(values ...string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, value := range values {
+		if len(value) < minSecretLength {
+			continue
+		}
+		if i := slices.Index(r.secrets, value); i >= 0 {
+			if i == len(r.secrets)-1 {
+				continue
+			}
+			r.secrets = slices.Delete(r.secrets, i, i+1)
+		}
+		r.secrets = append(r.secrets, value)
+		r.stale = true
+	}
+	if n := len(r.secrets) - maxSecrets; n > 0 {
+		r.secrets = slices.Delete(r.secrets, 0, n)
+	}
+}
+
+// AddPermanentSecrets registers secret values that are masked wherever they
+// appear and are never forgotten, such as the secrets of the runner itself.
+// Values shorter than 4 characters are ignored.
+func (r *Redactor) AddPermanentSecrets(values ...string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, value := range values {
+		if len(value) < minSecretLength || slices.Contains(r.permanentSecrets, value) {
+			continue
+		}
+		r.permanentSecrets = append(r.permanentSecrets, value)
+		r.stale = true
+	}
+}
+
+// secretReplacer returns the replacer of the registered secrets, rebuilding
+// it if secrets were registered since it was built.
+func (r *Redactor) secretReplacer() *strings.Replacer {
+	r.mu.RLock()
+	replacer, stale := r.replacer, r.stale
+	r.mu.RUnlock()
+	if !stale {
+		return replacer
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.stale {
+		return r.replacer
+	}
+	// Longer secrets are replaced first so that a secret containing another
+	// is masked entirely.
+	secrets := slices.Concat(r.permanentSecrets, r.secrets)
+	slices.SortStableFunc(secrets, func(a, b string) int {
+		return cmp.Compare(len(b), len(a))
+	})
+	oldnew := make([]string, 0, len(secrets)*2)
+	for _, secret := range secrets {
+		oldnew = append(oldnew, secret, param.RedactedValue)
+	}
+	r.replacer = strings.NewReplacer(oldnew...)
+	r.stale = false
+	return r.replacer
+}
+
+// Redact returns the message and attributes with sensitive values masked.
+func (r *Redactor) Redact(msg string, attrs []slog.Attr) (string, []slog.Attr) {
+	if r == nil {
+		return msg, attrs
+	}
+	return r.redactString(msg), r.redactAttrs(attrs)
+}
+
+func (r *Redactor) redactAttrs(attrs []slog.Attr) []slog.Attr {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = r.redactAttr(attr)
+	}
+	return redacted
+}
+
+func (r *Redactor) redactAttr(attr slog.Attr) slog.Attr {
+	if r.redactedKey(attr.Key) {
+		return slog.String(attr.Key, param.RedactedValue)
+	}
+
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindGroup:
+		return slog.Attr{Key: attr.Key, Value: slog.GroupValue(r.redactAttrs(value.Group())...)}
+	case slog.KindString:
+		return slog.String(attr.Key, r.redactString(value.String()))
+	case slog.KindAny:
+		switch v := value.Any().(type) {
+		case http.Header:
+			return slog.Any(attr.Key, http.Header(r.redactMultiMap(v)))
+		case map[string][]string:
+			return slog.Any(attr.Key, r.redactMultiMap(v))
+		case map[string]string:
+			return slog.Any(attr.Key, r.redactMap(v))
+		}
+		// Values are checked as they are rendered and only replaced by their
+		// redacted rendering if they contain a sensitive value.
+		rendered := renderValue(value)
+		if redacted := r.redactString(rendered); redacted != rendered {
+			return slog.String(attr.Key, redacted)
+		}
+	default:
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
+
+func (r *Redactor) redactMap(m map[string]string) map[string]string {
+	redacted := make(map[string]string, len(m))
+	for key, value := range m {
+		if r.redactedKey(key) {
+			redacted[key] = param.RedactedValue
+		} else {
+			redacted[key] = r.redactString(value)
+		}
+	}
+	return redacted
+}
+
+func (r *Redactor) redactMultiMap(m map[string][]string) map[string][]string {
+	redacted := make(map[string][]string, len(m))
+	for key, values := range m {
+		masked := make([]string, len(values))
+		for i, value := range values {
+			if r.redactedKey(key) {
+				masked[i] = param.RedactedValue
+			} else {
+				masked[i] = r.redactString(value)
+			}
+		}
+		redacted[key] = masked
+	}
+	return redacted
+}
+
+func (r *Redactor) redactedKey(key string) bool {
+	return r.keys[normalizeRedactKey(key)] || r.keys[normalizeRedactKey(lastKeySegment(key))]
+}
+
+func (r *Redactor) redactString(s string) string {
+	if replacer := r.secretReplacer(); replacer != nil {
+		s = replacer.Replace(s)
+	}
+	for _, pattern := range r.patterns {
+		s = pattern.ReplaceAllString(s, param.RedactedValue)
+	}
+	return s
+}
+
+func normalizeRedactKey(key string) string {
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+func lastKeySegment(key string) string {
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		return key[i+1:]
+	}
+	return key
+}
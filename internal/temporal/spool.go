@@ -0,0 +1,369 @@
+package temporal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/annexsh/annex-proto/go/gen/annex/tests/v1"
+	"github.com/annexsh/annex/log"
+	"go.temporal.io/sdk/client"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	spoolFileName              = "logs.jsonl"
+	spoolLockFileName          = "logs.lock"
+	defaultLogSpoolMaxSize     = 64 << 20
+	defaultSpoolReplayInterval = 5 * time.Second
+
+	// droppedLogsMetric counts test logs that were never published, tagged
+	// with the reason they were dropped.
+	droppedLogsMetric  = "annex_log_dropped"
+	dropReasonQueue    = "queue_full"
+	dropReasonSpool    = "spool_full"
+	dropReasonRejected = "rejected"
+	dropReasonFailed   = "publish_failed"
+	dropReasonClosed   = "publisher_closed"
+)
+
+// ErrLogSpooled is returned by a spooling publisher when a log could not be
+// published but was spooled to be published later.
+var ErrLogSpooled = errors.New("log spooled until annex is reachable")
+
+var errLogSpoolLocked = errors.New("directory is locked by another runner")
+
+type LogSpoolOption func(s *LogSpool)
+
+// WithLogSpoolMaxSize sets the maximum size in bytes of the spool file. Logs
+// are dropped while the spool is full.
+func WithLogSpoolMaxSize(size int64) LogSpoolOption {
+	return func(s *LogSpool) {
+		s.maxSize = size
+	}
+}
+
+// WithLogSpoolReplayInterval sets how often spooled logs are replayed.
+func WithLogSpoolReplayInterval(interval time.Duration) LogSpoolOption {
+	return func(s *LogSpool) {
+		s.replayInterval = interval
+	}
+}
+
+// LogSpool stores test logs that could not be published on disk and replays
+// them in order, with their original timestamps, once Annex is reachable
+// again. Logs spooled before the runner stopped are replayed when a runner
+// opens the same directory. The directory is locked while the spool is open so
+// that runners can't share it.
+type LogSpool struct {
+	pub            LogPublisher
+	logger         log.Logger
+	metrics        client.MetricsHandler
+	path           string
+	lock           *os.File
+	maxSize        int64
+	replayInterval time.Duration
+
+	mu   sync.Mutex
+	size int64
+
+	closeOnce sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// NewLogSpool opens the spool in dir, creating it if needed, and starts
+// replaying it to pub. It fails if another spool has dir open.
+func NewLogSpool(dir string, pub LogPublisher, logger log.Logger, metrics client.MetricsHandler, opts ...LogSpoolOption) (*LogSpool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create log spool directory: %w", err)
+	}
+	lock, err := lockSpool(filepath.Join(dir, spoolLockFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock log spool directory %s: %w", dir, err)
+	}
+	if metrics == nil {
+		metrics = client.MetricsNopHandler
+	}
+
+	s := &LogSpool{
+		pub:            pub,
+		logger:         logger,
+		metrics:        metrics,
+		path:           filepath.Join(dir, spoolFileName),
+		lock:           lock,
+		maxSize:        defaultLogSpoolMaxSize,
+		replayInterval: defaultSpoolReplayInterval,
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	info, err := os.Stat(s.path)
+	switch {
+	case err == nil:
+		s.size = info.Size()
+	case !errors.Is(err, os.ErrNotExist):
+		unlockSpool(lock)
+		return nil, fmt.Errorf("failed to open log spool: %w", err)
+	}
+
+	go s.run()
+	return s, nil
+}
+
+// Append spools logs. Logs that don't fit in the spool are dropped.
+func (s *LogSpool) Append(reqs ...*testsv1.PublishLogRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var buf bytes.Buffer
+	var dropped int64
+	for _, req := range reqs {
+		line, err := protojson.Marshal(req)
+		if err != nil {
+			s.logger.Error("failed to encode spooled log", "error", err)
+			dropped++
+			continue
+		}
+		if s.size+int64(buf.Len()+len(line)+1) > s.maxSize {
+			dropped++
+			continue
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	if dropped > 0 {
+		s.logger.Warn("dropped test logs: log spool full", "count", dropped)
+		countDropped(s.metrics, dropReasonSpool, dropped)
+	}
+	if buf.Len() == 0 {
+		return
+	}
+
+	if err := s.write(buf.Bytes()); err != nil {
+		s.logger.Error("failed to spool test logs", "error", err)
+		countDropped(s.metrics, dropReasonSpool, int64(len(reqs))-dropped)
+		return
+	}
+	s.size += int64(buf.Len())
+}
+
+func (s *LogSpool) write(data []byte) error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	// Spooled logs must survive the runner crashing.
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// pending reports whether logs are waiting to be replayed.
+func (s *LogSpool) pending() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size > 0
+}
+
+// Close replays the spool a last time, stops replaying it and unlocks its
+// directory. Logs that are still spooled remain on disk.
+func (s *LogSpool) Close() {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+		if err := unlockSpool(s.lock); err != nil {
+			s.logger.Error("failed to unlock log spool directory", "error", err)
+		}
+	})
+}
+
+func (s *LogSpool) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.replayInterval)
+	defer ticker.Stop()
+
+	for {
+		s.replay()
+		select {
+		case <-ticker.C:
+		case <-s.stop:
+			s.replay()
+			return
+		}
+	}
+}
+
+// replay publishes spooled logs in order until one fails to publish, then
+// removes the published logs from the spool.
+func (s *LogSpool) replay() {
+	lines, err := s.read()
+	if err != nil {
+		s.logger.Error("failed to read log spool", "error", err)
+		return
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	replayed := 0
+	for _, line := range lines {
+		req := &testsv1.PublishLogRequest{}
+		if err = protojson.Unmarshal(line, req); err != nil {
+			s.logger.Error("dropped corrupt spooled log", "error", err)
+			countDropped(s.metrics, dropReasonRejected, 1)
+			replayed++
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), logRequestTimeout)
+		_, err = s.pub.PublishLog(ctx, connect.NewRequest(req))
+		cancel()
+		if err != nil {
+			if isRetryablePublishError(err) {
+				break
+			}
+			s.logger.Error("dropped spooled log rejected by annex", "test_execution.id", req.TestExecutionId, "error", err)
+			countDropped(s.metrics, dropReasonRejected, 1)
+		}
+		replayed++
+	}
+
+	if replayed == 0 {
+		return
+	}
+	if err = s.remove(replayed); err != nil {
+		s.logger.Error("failed to remove replayed logs from log spool", "error", err)
+	}
+}
+
+func (s *LogSpool) read() ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var lines [][]byte
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, int(s.maxSize))
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			lines = append(lines, bytes.Clone(scanner.Bytes()))
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// remove removes the first n logs from the spool. Logs are only appended
+// while replaying, so they are the logs that were replayed.
+func (s *LogSpool) remove(n int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	for ; n > 0 && len(data) > 0; n-- {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			data = nil
+			break
+		}
+		data = data[i+1:]
+	}
+
+	if len(data) == 0 {
+		s.size = 0
+		return os.Remove(s.path)
+	}
+
+	// The remaining logs are written to a new file that replaces the spool so
+	// that a crash never loses them.
+	tmp := s.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	s.size = int64(len(data))
+	return nil
+}
+
+// spoolingPublisher spools logs that fail to publish because Annex is
+// unreachable.
+type spoolingPublisher struct {
+	LogPublisher
+	spool *LogSpool
+}
+
+// NewSpoolingPublisher returns a publisher that spools logs that fail to
+// publish because Annex is unreachable and then returns an error wrapping
+// ErrLogSpooled. Logs are also spooled without being published while the spool
+// has logs waiting to be replayed, so that they are published in order. It
+// returns pub if spool is nil.
+func NewSpoolingPublisher(pub LogPublisher, spool *LogSpool) LogPublisher {
+	if spool == nil {
+		return pub
+	}
+	return &spoolingPublisher{LogPublisher: pub, spool: spool}
+}
+
+func (p *spoolingPublisher) PublishLog(
+	ctx context.Context,
+	req *connect.Request[testsv1.PublishLogRequest],
+) (*connect.Response[testsv1.PublishLogResponse], error) {
+	if p.spool.pending() {
+		p.spool.Append(req.Msg)
+		return nil, ErrLogSpooled
+	}
+	res, err := p.LogPublisher.PublishLog(ctx, req)
+	if err != nil && isRetryablePublishError(err) {
+		p.spool.Append(req.Msg)
+		return nil, fmt.Errorf("%w: %w", ErrLogSpooled, err)
+	}
+	return res, err
+}
+
+// isRetryablePublishError reports whether a publish failed before Annex stored
+// the log, so that it can be published later without being duplicated. A
+// publish that timed out or failed with an unknown error may have been stored,
+// so the log is dropped rather than risk publishing it twice.
+func isRetryablePublishError(err error) bool {
+	switch connect.CodeOf(err) {
+	case connect.CodeUnavailable,
+		connect.CodeResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
+
+func countDropped(metrics client.MetricsHandler, reason string, n int64) {
+	metrics.WithTags(map[string]string{"reason": reason}).Counter(droppedLogsMetric).Inc(n)
+}
@@ -0,0 +1,28 @@
+//go:build !unix
+
+package temporal
+
+import (
+	"errors"
+	"os"
+)
+
+// lockSpool creates the lock file at path, failing if it exists. The lock
+// file is left behind if the runner dies and must then be removed by hand.
+func lockSpool(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0o600)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return nil, errLogSpoolLocked
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+func unlockSpool(f *os.File) error {
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Remove(f.Name())
+}
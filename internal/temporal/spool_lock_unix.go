@@ -0,0 +1,31 @@
+//go:build unix
+
+package temporal
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockSpool takes an exclusive lock on the lock file at path. The lock is
+// released by the OS if the runner dies, so a stale lock file never blocks
+// the next runner.
+func lockSpool(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, errLogSpoolLocked
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+func unlockSpool(f *os.File) error {
+	return f.Close()
+}
@@ -0,0 +1,212 @@
+package temporal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/annexsh/annex/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStoppedLogSpool returns a spool that has stopped replaying in the
+// background, so that tests replay it explicitly.
+func newStoppedLogSpool(t *testing.T, pub LogPublisher, metrics *fakeMetrics, opts ...LogSpoolOption) *LogSpool {
+	spool, err := NewLogSpool(t.TempDir(), pub, log.NewNopLogger(), metrics, opts...)
+	require.NoError(t, err)
+	spool.Close()
+	return spool
+}
+
+func publishLogs(t *testing.T, pub LogPublisher, msgs ...string) []error {
+	t.Helper()
+	var errs []error
+	for _, msg := range msgs {
+		_, err := pub.PublishLog(context.Background(), connect.NewRequest(newLogRequest("exec", msg)))
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func TestSpoolingPublisher_PublishesInOrder(t *testing.T) {
+	pub := &fakePublisher{}
+	spool := newStoppedLogSpool(t, pub, newFakeMetrics())
+	spooling := NewSpoolingPublisher(pub, spool)
+
+	pub.setErr(connect.NewError(connect.CodeUnavailable, errors.New("down")))
+	for _, err := range publishLogs(t, spooling, "1", "2") {
+		assert.ErrorIs(t, err, ErrLogSpooled)
+	}
+
+	// Annex is reachable again, but older logs are still spooled.
+	pub.setErr(nil)
+	for _, err := range publishLogs(t, spooling, "3") {
+		assert.ErrorIs(t, err, ErrLogSpooled)
+	}
+	assert.Empty(t, pub.messages())
+
+	spool.replay()
+	assert.Equal(t, []string{"1", "2", "3"}, pub.messages())
+	assert.False(t, spool.pending())
+
+	for _, err := range publishLogs(t, spooling, "4") {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []string{"1", "2", "3", "4"}, pub.messages())
+}
+
+func TestSpoolingPublisher_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantSpooled bool
+	}{
+		{
+			name:        "unavailable",
+			err:         connect.NewError(connect.CodeUnavailable, errors.New("down")),
+			wantSpooled: true,
+		},
+		{
+			name:        "resource exhausted",
+			err:         connect.NewError(connect.CodeResourceExhausted, errors.New("slow down")),
+			wantSpooled: true,
+		},
+		{
+			name: "deadline exceeded",
+			err:  connect.NewError(connect.CodeDeadlineExceeded, errors.New("timeout")),
+		},
+		{
+			name: "unknown",
+			err:  errors.New("unknown"),
+		},
+		{
+			name: "invalid argument",
+			err:  connect.NewError(connect.CodeInvalidArgument, errors.New("bad log")),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub := &fakePublisher{err: tt.err}
+			spool := newStoppedLogSpool(t, pub, newFakeMetrics())
+
+			errs := publishLogs(t, NewSpoolingPublisher(pub, spool), "1")
+			assert.ErrorIs(t, errs[0], tt.err)
+			assert.Equal(t, tt.wantSpooled, errors.Is(errs[0], ErrLogSpooled))
+			assert.Equal(t, tt.wantSpooled, spool.pending())
+		})
+	}
+}
+
+func TestLogSpool_Replay(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantPending bool
+		wantDropped int64
+	}{
+		{
+			name: "published",
+		},
+		{
+			name:        "unavailable keeps logs",
+			err:         connect.NewError(connect.CodeUnavailable, errors.New("down")),
+			wantPending: true,
+		},
+		{
+			name:        "deadline exceeded drops logs",
+			err:         connect.NewError(connect.CodeDeadlineExceeded, errors.New("timeout")),
+			wantDropped: 2,
+		},
+		{
+			name:        "rejected drops logs",
+			err:         connect.NewError(connect.CodeInvalidArgument, errors.New("bad log")),
+			wantDropped: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub := &fakePublisher{}
+			metrics := newFakeMetrics()
+			spool := newStoppedLogSpool(t, pub, metrics)
+			spool.Append(newLogRequest("exec", "1"), newLogRequest("exec", "2"))
+
+			pub.setErr(tt.err)
+			spool.replay()
+
+			assert.Equal(t, tt.wantPending, spool.pending())
+			assert.Equal(t, tt.wantDropped, metrics.dropped(dropReasonRejected))
+			if tt.err == nil {
+				assert.Equal(t, []string{"1", "2"}, pub.messages())
+				assert.NoFileExists(t, spool.path)
+			}
+		})
+	}
+}
+
+func TestLogSpool_RemovesReplayedLogs(t *testing.T) {
+	pub := &fakePublisher{}
+	spool := newStoppedLogSpool(t, pub, newFakeMetrics())
+	spool.Append(newLogRequest("exec", "1"), newLogRequest("exec", "2"), newLogRequest("exec", "3"))
+
+	require.NoError(t, spool.remove(2))
+	lines, err := spool.read()
+	require.NoError(t, err)
+	require.Len(t, lines, 1)
+	assert.Contains(t, string(lines[0]), `"message":"3"`)
+
+	info, err := os.Stat(spool.path)
+	require.NoError(t, err)
+	assert.Equal(t, info.Size(), spool.size)
+}
+
+func TestLogSpool_MaxSize(t *testing.T) {
+	metrics := newFakeMetrics()
+	spool := newStoppedLogSpool(t, &fakePublisher{}, metrics, WithLogSpoolMaxSize(100))
+
+	spool.Append(newLogRequest("exec", "1"), newLogRequest("exec", "2"), newLogRequest("exec", "3"))
+
+	lines, err := spool.read()
+	require.NoError(t, err)
+	assert.Len(t, lines, 1)
+	assert.Equal(t, int64(2), metrics.dropped(dropReasonSpool))
+}
+
+func TestLogSpool_ReplaysAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	pub := &fakePublisher{err: connect.NewError(connect.CodeUnavailable, errors.New("down"))}
+
+	spool, err := NewLogSpool(dir, pub, log.NewNopLogger(), nil, WithLogSpoolReplayInterval(time.Hour))
+	require.NoError(t, err)
+	spool.Append(newLogRequest("exec", "1"), newLogRequest("exec", "2"))
+	spool.Close()
+
+	pub.setErr(nil)
+	spool, err = NewLogSpool(dir, pub, log.NewNopLogger(), nil, WithLogSpoolReplayInterval(time.Hour))
+	require.NoError(t, err)
+	spool.Close()
+
+	assert.Equal(t, []string{"1", "2"}, pub.messages())
+	assert.NoFileExists(t, filepath.Join(dir, spoolFileName))
+}
+
+func TestLogSpool_Lock(t *testing.T) {
+	dir := t.TempDir()
+
+	spool, err := NewLogSpool(dir, &fakePublisher{}, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	_, err = NewLogSpool(dir, &fakePublisher{}, log.NewNopLogger(), nil)
+	assert.ErrorIs(t, err, errLogSpoolLocked)
+
+	spool.Close()
+	spool, err = NewLogSpool(dir, &fakePublisher{}, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	spool.Close()
+}
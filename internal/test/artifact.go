@@ -0,0 +1,225 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/annexsh/annex/test"
+	"go.temporal.io/sdk/activity"
+	temporalsdk "go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/annexsh/annex-sdk-go/internal/temporal"
+)
+
+const (
+	artifactTimeout = time.Minute
+	// maxArtifactURILength limits artifact references, which are written to
+	// logs and case responses, so that sinks can't embed artifact content.
+	maxArtifactURILength = 2048
+)
+
+// ErrNoArtifactSink is returned when an artifact is attached by a runner
+// without an artifact sink.
+var ErrNoArtifactSink = errors.New("no artifact sink configured: set TestSuiteRunnerConfig.ArtifactSink to a local or blob artifact sink")
+
+// Artifact is a reference to a file attached to a test or case execution.
+type Artifact struct {
+	Name            string
+	ContentType     string
+	TestExecutionID string
+	// CaseExecutionID is empty for artifacts attached by the test body.
+	CaseExecutionID test.CaseExecutionID
+	Size            int64
+	// URI locates the artifact content in the sink that stored it.
+	URI string
+}
+
+// ArtifactSink stores artifact content. The artifact passed to StoreArtifact
+// has every field set except Size and URI.
+type ArtifactSink interface {
+	StoreArtifact(ctx context.Context, artifact Artifact, r io.Reader) (uri string, err error)
+}
+
+type artifactsKey struct{}
+
+// artifactList collects the artifacts attached during a case execution so that
+// they are recorded in the case response.
+type artifactList struct {
+	mu        sync.Mutex
+	artifacts []Artifact
+}
+
+func (l *artifactList) add(artifact Artifact) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.artifacts = append(l.artifacts, artifact)
+}
+
+func (l *artifactList) list() []Artifact {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.artifacts
+}
+
+// AttachArtifact stores an artifact for the case executing in ctx and records
+// its reference in the case logs and response.
+func AttachArtifact(ctx context.Context, name string, contentType string, r io.Reader) (Artifact, error) {
+	if err := validateArtifactName(name); err != nil {
+		return Artifact{}, err
+	}
+
+	cfg, ok := temporal.TestLogConfigFromContext(ctx)
+	if !ok {
+		return Artifact{}, errors.New("artifacts can only be attached during a case execution")
+	}
+	artifact := Artifact{
+		Name:            name,
+		ContentType:     contentType,
+		TestExecutionID: cfg.TestExecID.String(),
+	}
+	if cfg.CaseExecID != nil {
+		artifact.CaseExecutionID = *cfg.CaseExecID
+	}
+
+	storeCtx, cancel := context.WithTimeout(ctx, artifactTimeout)
+	defer cancel()
+	artifact, err := storeArtifact(storeCtx, RuntimeFromContext(ctx).ArtifactSink(), artifact, r)
+	if err != nil {
+		return Artifact{}, err
+	}
+
+	if list, ok := ctx.Value(artifactsKey{}).(*artifactList); ok {
+		list.add(artifact)
+	}
+	activity.GetLogger(ctx).Info(artifactLogMessage(artifact), artifactKeyvals(artifact)...)
+
+	return artifact, nil
+}
+
+// StoreArtifactRequest is the input of ArtifactActivity.StoreArtifact.
+type StoreArtifactRequest struct {
+	Artifact Artifact
+	Data     []byte
+}
+
+// ArtifactActivity stores artifacts attached by a test workflow. It must be
+// executed as a local activity so that artifact content is never written to
+// the workflow history.
+type ArtifactActivity struct {
+	sink ArtifactSink
+}
+
+func NewArtifactActivity(sink ArtifactSink) *ArtifactActivity {
+	return &ArtifactActivity{
+		sink: sink,
+	}
+}
+
+func (a *ArtifactActivity) StoreArtifact(ctx context.Context, req StoreArtifactRequest) (*Artifact, error) {
+	if a.sink == nil {
+		return nil, temporalsdk.NewNonRetryableApplicationError(ErrNoArtifactSink.Error(), "NoArtifactSink", ErrNoArtifactSink)
+	}
+	artifact, err := storeArtifact(ctx, a.sink, req.Artifact, bytes.NewReader(req.Data))
+	if err != nil {
+		return nil, err
+	}
+	return &artifact, nil
+}
+
+// AttachWorkflowArtifact stores an artifact for the test executing in ctx and
+// records its reference in the test logs.
+func AttachWorkflowArtifact(ctx workflow.Context, name string, contentType string, r io.Reader) (Artifact, error) {
+	if err := validateArtifactName(name); err != nil {
+		return Artifact{}, err
+	}
+
+	testExecID, err := test.ParseTestWorkflowID(workflow.GetInfo(ctx).WorkflowExecution.ID)
+	if err != nil {
+		return Artifact{}, err
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Artifact{}, fmt.Errorf("failed to read artifact %s: %w", name, err)
+	}
+
+	req := StoreArtifactRequest{
+		Artifact: Artifact{
+			Name:            name,
+			ContentType:     contentType,
+			TestExecutionID: testExecID.String(),
+		},
+		Data: data,
+	}
+
+	lctx := workflow.WithLocalActivityOptions(ctx, workflow.LocalActivityOptions{
+		StartToCloseTimeout: artifactTimeout,
+		RetryPolicy: &temporalsdk.RetryPolicy{
+			MaximumAttempts: 3,
+		},
+	})
+
+	var artifactActivity *ArtifactActivity
+	var artifact Artifact
+	if err = workflow.ExecuteLocalActivity(lctx, artifactActivity.StoreArtifact, req).Get(lctx, &artifact); err != nil {
+		return Artifact{}, err
+	}
+
+	workflow.GetLogger(ctx).Info(artifactLogMessage(artifact), artifactKeyvals(artifact)...)
+
+	return artifact, nil
+}
+
+func storeArtifact(ctx context.Context, sink ArtifactSink, artifact Artifact, r io.Reader) (Artifact, error) {
+	if sink == nil {
+		return Artifact{}, ErrNoArtifactSink
+	}
+	counter := &countingReader{r: r}
+	uri, err := sink.StoreArtifact(ctx, artifact, counter)
+	if err != nil {
+		return Artifact{}, fmt.Errorf("failed to store artifact %s: %w", artifact.Name, err)
+	}
+	if len(uri) > maxArtifactURILength {
+		return Artifact{}, fmt.Errorf("failed to store artifact %s: sink returned a %d byte URI, the maximum is %d bytes", artifact.Name, len(uri), maxArtifactURILength)
+	}
+	artifact.Size = counter.n
+	artifact.URI = uri
+	return artifact, nil
+}
+
+func validateArtifactName(name string) error {
+	if name == "" || !filepath.IsLocal(name) {
+		return fmt.Errorf("invalid artifact name: %q", name)
+	}
+	return nil
+}
+
+func artifactLogMessage(artifact Artifact) string {
+	return fmt.Sprintf("artifact attached: %s (%s, %d bytes) %s", artifact.Name, artifact.ContentType, artifact.Size, artifact.URI)
+}
+
+func artifactKeyvals(artifact Artifact) []any {
+	return []any{
+		"artifact.name", artifact.Name,
+		"artifact.content_type", artifact.ContentType,
+		"artifact.size", artifact.Size,
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
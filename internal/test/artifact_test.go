@@ -0,0 +1,55 @@
+package test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type uriSink string
+
+func (s uriSink) StoreArtifact(_ context.Context, _ Artifact, r io.Reader) (string, error) {
+	_, err := io.Copy(io.Discard, r)
+	return string(s), err
+}
+
+func TestStoreArtifact(t *testing.T) {
+	tests := []struct {
+		name    string
+		sink    ArtifactSink
+		want    Artifact
+		wantErr string
+	}{
+		{
+			name: "stored",
+			sink: uriSink("blob:exec/test/report.txt"),
+			want: Artifact{Name: "report.txt", Size: 5, URI: "blob:exec/test/report.txt"},
+		},
+		{
+			name:    "no sink",
+			sink:    nil,
+			wantErr: ErrNoArtifactSink.Error(),
+		},
+		{
+			name:    "uri too long",
+			sink:    uriSink("data:text/plain;base64," + strings.Repeat("A", maxArtifactURILength)),
+			wantErr: "sink returned a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := storeArtifact(context.Background(), tt.sink, Artifact{Name: "report.txt"}, strings.NewReader("hello"))
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+
+	tlog "go.temporal.io/sdk/log"
+)
+
+type caseOutputKey struct{}
+
+// CaseOutput holds writers that publish output written during a case as case
+// logs, one log per line.
+type CaseOutput struct {
+	Stdout *LineWriter
+	Stderr *LineWriter
+	Log    *LineWriter
+}
+
+func newCaseOutput(logger tlog.Logger) *CaseOutput {
+	return &CaseOutput{
+		Stdout: &LineWriter{emit: func(line string) { logger.Info(line, "stream", "stdout") }},
+		Stderr: &LineWriter{emit: func(line string) { logger.Warn(line, "stream", "stderr") }},
+		Log:    &LineWriter{emit: func(line string) { logger.Info(line, "stream", "log") }},
+	}
+}
+
+// CaseOutputFromContext returns the output writers of the case executing in
+// ctx.
+func CaseOutputFromContext(ctx context.Context) *CaseOutput {
+	out, ok := ctx.Value(caseOutputKey{}).(*CaseOutput)
+	if !ok {
+		panic("context value is not case output")
+	}
+	return out
+}
+
+func (o *CaseOutput) flush() {
+	o.Stdout.Flush()
+	o.Stderr.Flush()
+	o.Log.Flush()
+}
+
+// LineWriter calls emit for every complete line written to it.
+type LineWriter struct {
+	mu   sync.Mutex
+	buf  []byte
+	emit func(line string)
+}
+
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(strings.TrimSuffix(string(w.buf[:i]), "\r"))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush emits any partial line.
+func (w *LineWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.emit(string(w.buf))
+		w.buf = nil
+	}
+}
@@ -0,0 +1,36 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   []string
+	}{
+		{name: "single line", writes: []string{"hello\n"}, want: []string{"hello"}},
+		{name: "several lines", writes: []string{"a\nb\nc\n"}, want: []string{"a", "b", "c"}},
+		{name: "split writes", writes: []string{"hel", "lo\nwor", "ld\n"}, want: []string{"hello", "world"}},
+		{name: "crlf", writes: []string{"a\r\n"}, want: []string{"a"}},
+		{name: "empty line", writes: []string{"\n"}, want: []string{""}},
+		{name: "partial line flushed", writes: []string{"a\nb"}, want: []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			w := &LineWriter{emit: func(line string) { got = append(got, line) }}
+			for _, s := range tt.writes {
+				n, err := w.Write([]byte(s))
+				assert.NoError(t, err)
+				assert.Equal(t, len(s), n)
+			}
+			w.Flush()
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
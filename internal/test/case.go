@@ -8,6 +8,7 @@ import (
 	"github.com/annexsh/annex/test"
 	"go.temporal.io/api/common/v1"
 	"go.temporal.io/sdk/activity"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/annexsh/annex-sdk-go/internal/temporal"
 )
@@ -17,9 +18,10 @@ type resultKey struct{}
 type CaseExecutor func(ctx context.Context, payload *common.Payload) (any, error)
 
 type CaseResponse[T any] struct {
-	Result   T
-	Finished time.Time
-	Duration time.Duration
+	Result    T
+	Finished  time.Time
+	Duration  time.Duration
+	Artifacts []Artifact
 }
 
 func ExecuteCase(ctx context.Context, a func(ctx context.Context)) (*CaseResponse[any], error) {
@@ -36,6 +38,7 @@ func ExecuteCase(ctx context.Context, a func(ctx context.Context)) (*CaseRespons
 	}
 
 	ctx = temporal.ContextWithTestLogConfig(ctx, temporal.TestLogConfig{
+		Context:    RuntimeFromContext(ctx).TestContext(),
 		TestExecID: testExecID,
 		CaseExecID: &caseExecID,
 	})
@@ -43,21 +46,43 @@ func ExecuteCase(ctx context.Context, a func(ctx context.Context)) (*CaseRespons
 	resultCh := make(chan any, 1)
 	ctx = context.WithValue(ctx, resultKey{}, resultCh)
 
+	artifacts := &artifactList{}
+	ctx = context.WithValue(ctx, artifactsKey{}, artifacts)
+
+	output := newCaseOutput(activity.GetLogger(ctx))
+	ctx = context.WithValue(ctx, caseOutputKey{}, output)
+
 	start := time.Now()
 	res := &CaseResponse[any]{}
 
-	if err := execWithRecover(func() {
+	err = execWithRecover(func() {
 		a(ctx)
-	}); err != nil {
+	})
+	output.flush()
+	if err != nil {
 		return nil, fmt.Errorf("case execution failed: %w", err)
 	}
 
 	res.Finished = time.Now()
 	res.Duration = res.Finished.Sub(start)
+	res.Artifacts = artifacts.list()
 
 	select {
 	case result := <-resultCh:
-		res.Result = result
+		rt := RuntimeFromContext(ctx)
+		if msg, ok := result.(proto.Message); ok {
+			// Protobuf messages can't be encoded as part of the JSON response,
+			// so they are stored as a payload encoded with the data converter.
+			res.Result, err = rt.Converter().ToPayload(msg)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal case result: %w", err)
+			}
+			break
+		}
+		res.Result, err = rt.SealSecrets(result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt case result secrets: %w", err)
+		}
 	default:
 	}
 	return res, nil
@@ -0,0 +1,36 @@
+package test
+
+import (
+	"github.com/annexsh/annex/test"
+	"go.temporal.io/api/common/v1"
+)
+
+// Checkpoint is carried across a continue-as-new so that the new workflow run
+// resumes the same test execution where the previous run stopped.
+type Checkpoint struct {
+	LastCaseExecID test.CaseExecutionID
+	State          *common.Payload
+	Output         *Output
+	Table          *TableCheckpoint `json:",omitempty"`
+}
+
+// TableCheckpoint records the progress of a table test whose row continued as
+// new, so that the next run resumes the table at that row instead of running
+// the finished rows again.
+type TableCheckpoint struct {
+	// Row is the row that continued as new. Rows sorted before it have
+	// finished.
+	Row string
+	// Failed holds the finished rows that failed.
+	Failed []string `json:",omitempty"`
+}
+
+type continueAsNew struct {
+	checkpoint Checkpoint
+}
+
+// ContinueAsNew unwinds the test body and continues the test workflow as a new
+// run seeded with the checkpoint. It must be called from the test body itself.
+func ContinueAsNew(checkpoint Checkpoint) {
+	panic(&continueAsNew{checkpoint: checkpoint})
+}
@@ -1,14 +1,33 @@
 package test
 
 import (
+	"maps"
+	"reflect"
+
 	"github.com/annexsh/annex-proto/go/gen/annex/tests/v1"
 	"go.temporal.io/api/common/v1"
 	"go.temporal.io/sdk/converter"
+
+	"github.com/annexsh/annex-sdk-go/internal/param"
 )
 
-func DecodeParam[P any](payload *testsv1.Payload) (P, error) {
+// HasInput reports whether a test payload holds an input rather than only
+// metadata such as the log level.
+func HasInput(payload *testsv1.Payload) bool {
+	return payload != nil && len(payload.Data) > 0
+}
+
+func DecodeParam[P any](dc converter.DataConverter, payload *testsv1.Payload) (P, error) {
 	converted := convertAnnexPayload(payload)
-	dc := converter.GetDefaultDataConverter()
+	if isPlainJSON(converted) && param.IsProtoMessage(reflect.TypeFor[P]()) {
+		// Inputs edited in Annex may be labelled as plain JSON, but protobuf
+		// messages must be decoded as proto JSON.
+		converted = &common.Payload{
+			Metadata: maps.Clone(converted.Metadata),
+			Data:     converted.Data,
+		}
+		converted.Metadata[converter.MetadataEncoding] = []byte(converter.MetadataEncodingProtoJSON)
+	}
 	var param P
 	if err := dc.FromPayload(converted, &param); err != nil {
 		return param, err
@@ -16,8 +35,7 @@ func DecodeParam[P any](payload *testsv1.Payload) (P, error) {
 	return param, nil
 }
 
-func DecodeTemporalParam[P any](payload *common.Payload) (P, error) {
-	dc := converter.GetDefaultDataConverter()
+func DecodeTemporalParam[P any](dc converter.DataConverter, payload *common.Payload) (P, error) {
 	var param P
 	if err := dc.FromPayload(payload, &param); err != nil {
 		return param, err
@@ -25,6 +43,10 @@ func DecodeTemporalParam[P any](payload *common.Payload) (P, error) {
 	return param, nil
 }
 
+func isPlainJSON(payload *common.Payload) bool {
+	return string(payload.Metadata[converter.MetadataEncoding]) == converter.MetadataEncodingJSON
+}
+
 func convertAnnexPayload(testPayload *testsv1.Payload) *common.Payload {
 	return &common.Payload{
 		Metadata: testPayload.Metadata,
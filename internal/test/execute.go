@@ -11,22 +11,97 @@ import (
 	"github.com/annexsh/annex-sdk-go/internal/temporal"
 )
 
-type TestExecutor func(ctx workflow.Context, payload *testsv1.Payload) error
+type TestExecutor func(ctx workflow.Context, payload *testsv1.Payload, checkpoint *Checkpoint) (*Output, error)
 
-func ExecuteTest(ctx workflow.Context, wf func(ctx workflow.Context)) error {
+// ExecuteTest runs a test body. It returns the output and annotations set by
+// the test, or nil if there are none.
+func ExecuteTest(ctx workflow.Context, payload *testsv1.Payload, checkpoint *Checkpoint, wf func(ctx workflow.Context)) (*Output, error) {
 	weInfo := workflow.GetInfo(ctx)
 	testExecID, err := test.ParseTestWorkflowID(weInfo.WorkflowExecution.ID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	ctx = temporal.WorkflowContextWithTestLogConfig(ctx, temporal.TestLogConfig{
+		Context:    RuntimeFromWorkflowContext(ctx).TestContext(),
 		TestExecID: testExecID,
+		LogLevels:  logLevelsFromWorkflowContext(ctx),
 	})
 
-	return execWithRecover(func() {
-		wf(ctx)
+	out := &Output{}
+	if checkpoint != nil && checkpoint.Output != nil {
+		out = checkpoint.Output
+	}
+	ctx = workflowContextWithOutput(ctx, out)
+
+	var next *continueAsNew
+	err = execWithRecover(func() {
+		next = catchContinueAsNew(func() {
+			wf(ctx)
+		})
 	})
+	if err != nil {
+		return nil, err
+	}
+	if next == nil {
+		if out.empty() {
+			return nil, nil
+		}
+		return out, nil
+	}
+
+	// The output is carried over so that the final run returns everything set
+	// by the test.
+	next.checkpoint.Output = out
+
+	// A nil payload must be passed as an untyped nil so the next run decodes it
+	// as nil rather than as an empty payload.
+	var input any
+	if payload != nil {
+		input = payload
+	}
+	return nil, workflow.NewContinueAsNewError(ctx, weInfo.WorkflowType.Name, input, &next.checkpoint)
+}
+
+// ExecuteSubtest runs part of a test body, recovering its failure so that the
+// rest of the test can continue. A continue-as-new is not recovered.
+func ExecuteSubtest(fn func()) error {
+	var next *continueAsNew
+	err := execWithRecover(func() {
+		next = catchContinueAsNew(fn)
+	})
+	if next != nil {
+		panic(next)
+	}
+	return err
+}
+
+// ExecuteTableRow runs a row of a table test like ExecuteSubtest. If the row
+// continues as new, the table progress is recorded in the checkpoint.
+func ExecuteTableRow(progress TableCheckpoint, fn func()) error {
+	var next *continueAsNew
+	err := execWithRecover(func() {
+		next = catchContinueAsNew(fn)
+	})
+	if next != nil {
+		next.checkpoint.Table = &progress
+		panic(next)
+	}
+	return err
+}
+
+func catchContinueAsNew(fn func()) (next *continueAsNew) {
+	defer func() {
+		if r := recover(); r != nil {
+			can, ok := r.(*continueAsNew)
+			if !ok {
+				panic(r)
+			}
+			next = can
+		}
+	}()
+	fn()
+	return nil
 }
 
 func execWithRecover(wrapper func()) (err error) {
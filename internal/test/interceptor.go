@@ -0,0 +1,49 @@
+package test
+
+import (
+	"context"
+
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/workflow"
+)
+
+type workerRuntimeInterceptor struct {
+	interceptor.WorkerInterceptorBase
+	runtime *Runtime
+}
+
+// NewWorkerInterceptor adds the runtime to the context of every workflow and
+// activity executed by the worker.
+func NewWorkerInterceptor(rt *Runtime) interceptor.WorkerInterceptor {
+	return &workerRuntimeInterceptor{runtime: rt}
+}
+
+func (i *workerRuntimeInterceptor) InterceptActivity(_ context.Context, next interceptor.ActivityInboundInterceptor) interceptor.ActivityInboundInterceptor {
+	in := &activityInboundRuntimeInterceptor{runtime: i.runtime}
+	in.Next = next
+	return in
+}
+
+func (i *workerRuntimeInterceptor) InterceptWorkflow(_ workflow.Context, next interceptor.WorkflowInboundInterceptor) interceptor.WorkflowInboundInterceptor {
+	in := &workflowInboundRuntimeInterceptor{runtime: i.runtime}
+	in.Next = next
+	return in
+}
+
+type activityInboundRuntimeInterceptor struct {
+	interceptor.ActivityInboundInterceptorBase
+	runtime *Runtime
+}
+
+func (i *activityInboundRuntimeInterceptor) ExecuteActivity(ctx context.Context, in *interceptor.ExecuteActivityInput) (any, error) {
+	return i.Next.ExecuteActivity(ContextWithRuntime(ctx, i.runtime), in)
+}
+
+type workflowInboundRuntimeInterceptor struct {
+	interceptor.WorkflowInboundInterceptorBase
+	runtime *Runtime
+}
+
+func (i *workflowInboundRuntimeInterceptor) ExecuteWorkflow(ctx workflow.Context, in *interceptor.ExecuteWorkflowInput) (any, error) {
+	return i.Next.ExecuteWorkflow(WorkflowContextWithRuntime(ctx, i.runtime), in)
+}
@@ -0,0 +1,39 @@
+package test
+
+import (
+	"fmt"
+
+	"github.com/annexsh/annex-proto/go/gen/annex/tests/v1"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/annexsh/annex-sdk-go/internal/temporal"
+)
+
+// LogLevelMetadataKey is the test input payload metadata key that sets the
+// minimum level of logs published for a single test execution, e.g. "debug".
+const LogLevelMetadataKey = "log-level"
+
+type logLevelsKey struct{}
+
+// LogLevelTest returns a test executor that overrides the runner log levels
+// with levels. The publish level is further overridden by the level set in the
+// test input metadata, which is kept across a continue-as-new.
+func LogLevelTest(levels temporal.LogLevelOverrides, executor TestExecutor) TestExecutor {
+	return func(ctx workflow.Context, payload *testsv1.Payload, checkpoint *Checkpoint) (*Output, error) {
+		overrides := levels
+		if raw, ok := payload.GetMetadata()[LogLevelMetadataKey]; ok {
+			level, err := temporal.ParseLogLevel(string(raw))
+			if err != nil {
+				return nil, fmt.Errorf("invalid test input %s metadata: %w", LogLevelMetadataKey, err)
+			}
+			overrides.Publish = &level
+		}
+		ctx = workflow.WithValue(ctx, logLevelsKey{}, overrides)
+		return executor(ctx, payload, checkpoint)
+	}
+}
+
+func logLevelsFromWorkflowContext(ctx workflow.Context) temporal.LogLevelOverrides {
+	levels, _ := ctx.Value(logLevelsKey{}).(temporal.LogLevelOverrides)
+	return levels
+}
@@ -0,0 +1,116 @@
+package test
+
+import (
+	"encoding/json"
+	"maps"
+	"reflect"
+
+	"go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/workflow"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/annexsh/annex-sdk-go/internal/param"
+)
+
+// AnnotationsMemoKey is the test workflow memo key holding the test
+// annotations as a JSON object.
+const AnnotationsMemoKey = "annotations"
+
+// OutputMemoKey is the test workflow memo key holding the test output with its
+// secret fields redacted.
+const OutputMemoKey = "output"
+
+// Output is the result of a test workflow.
+type Output struct {
+	// Output is the value set by the test encoded with the runtime data
+	// converter. Secret fields are encrypted.
+	Output      *common.Payload   `json:",omitempty"`
+	Annotations map[string]string `json:",omitempty"`
+}
+
+func (o *Output) empty() bool {
+	return o.Output == nil && len(o.Annotations) == 0
+}
+
+type outputKey struct{}
+
+func workflowContextWithOutput(ctx workflow.Context, out *Output) workflow.Context {
+	return workflow.WithValue(ctx, outputKey{}, out)
+}
+
+func outputFromWorkflowContext(ctx workflow.Context) *Output {
+	out, ok := ctx.Value(outputKey{}).(*Output)
+	if !ok {
+		panic("test output can only be set from a test")
+	}
+	return out
+}
+
+// SetOutput sets the output of the test executing in ctx. The output is stored
+// as the workflow result with its secret fields encrypted, and in the memo with
+// its secret fields redacted.
+func SetOutput(ctx workflow.Context, output any) error {
+	out := outputFromWorkflowContext(ctx)
+	rt := RuntimeFromWorkflowContext(ctx)
+
+	payload, err := rt.EncodeSealed(ctx, output)
+	if err != nil {
+		return err
+	}
+	memo, err := outputMemo(output)
+	if err != nil {
+		return err
+	}
+	if err = workflow.UpsertMemo(ctx, map[string]any{
+		OutputMemoKey: memo,
+	}); err != nil {
+		return err
+	}
+	out.Output = payload
+	return nil
+}
+
+// outputMemo returns the memo value of a test output. Proto messages are stored
+// as proto JSON so that the memo can always be read as plain JSON.
+func outputMemo(output any) (any, error) {
+	if msg, ok := output.(proto.Message); ok {
+		data, err := protojson.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(data), nil
+	}
+	return param.Redact(output), nil
+}
+
+// Annotate adds a key/value annotation to the test executing in ctx. The
+// annotations are stored in the workflow result and memo.
+func Annotate(ctx workflow.Context, key string, value string) error {
+	out := outputFromWorkflowContext(ctx)
+	annotations := maps.Clone(out.Annotations)
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[key] = value
+
+	if err := workflow.UpsertMemo(ctx, map[string]any{
+		AnnotationsMemoKey: annotations,
+	}); err != nil {
+		return err
+	}
+	out.Annotations = annotations
+	return nil
+}
+
+// DecodeOutput decodes a test output payload into the value ptr points to and
+// decrypts its secret fields.
+func DecodeOutput(rt *Runtime, payload *common.Payload, ptr any) error {
+	if err := rt.Converter().FromPayload(payload, ptr); err != nil {
+		return err
+	}
+	if t := reflect.TypeOf(ptr); t.Kind() == reflect.Pointer && param.IsProtoMessage(t.Elem()) {
+		return nil
+	}
+	return rt.OpenSecrets(ptr)
+}
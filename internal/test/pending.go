@@ -13,7 +13,7 @@ type Pending struct {
 }
 
 func (p *Pending) IsReady() bool {
-	return p.err != nil || p.IsReady()
+	return p.err != nil || p.future.IsReady()
 }
 
 func NewPending(id test.CaseExecutionID, name string, base workflow.Future) *Pending {
@@ -39,3 +39,11 @@ func GetPendingFuture(pending *Pending) workflow.Future {
 func GetPendingError(pending *Pending) error {
 	return pending.err
 }
+
+func GetPendingName(pending *Pending) string {
+	return pending.name
+}
+
+func GetPendingID(pending *Pending) test.CaseExecutionID {
+	return pending.id
+}
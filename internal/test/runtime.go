@@ -0,0 +1,161 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/annexsh/annex-sdk-go/internal/param"
+	"github.com/annexsh/annex-sdk-go/internal/temporal"
+)
+
+const defaultContext = "default"
+
+// ErrNoSecretKey is returned when a value with secret fields is encoded by a
+// runner without a secret key.
+var ErrNoSecretKey = errors.New("value has fields tagged secret but no runner secret key is configured")
+
+type runtimeKey struct{}
+
+// Runtime holds the runner settings used while executing tests and cases. It
+// is added to workflow and activity contexts by the worker interceptor.
+type Runtime struct {
+	// Context is the Annex context of the runner.
+	Context       string
+	DataConverter converter.DataConverter
+	Secrets       *param.SecretCipher
+	Artifacts     ArtifactSink
+	// Redactor masks sensitive values in test and case logs.
+	Redactor *temporal.Redactor
+}
+
+func ContextWithRuntime(ctx context.Context, rt *Runtime) context.Context {
+	return context.WithValue(ctx, runtimeKey{}, rt)
+}
+
+// RuntimeFromContext returns the runtime of an activity context, or nil if the
+// activity is not executed by a test suite runner.
+func RuntimeFromContext(ctx context.Context) *Runtime {
+	rt, _ := ctx.Value(runtimeKey{}).(*Runtime)
+	return rt
+}
+
+func WorkflowContextWithRuntime(ctx workflow.Context, rt *Runtime) workflow.Context {
+	return workflow.WithValue(ctx, runtimeKey{}, rt)
+}
+
+// RuntimeFromWorkflowContext returns the runtime of a workflow context, or nil
+// if the workflow is not executed by a test suite runner.
+func RuntimeFromWorkflowContext(ctx workflow.Context) *Runtime {
+	rt, _ := ctx.Value(runtimeKey{}).(*Runtime)
+	return rt
+}
+
+// Converter returns the data converter used for test params, case inputs and
+// case results.
+func (r *Runtime) Converter() converter.DataConverter {
+	if r == nil || r.DataConverter == nil {
+		return converter.GetDefaultDataConverter()
+	}
+	return r.DataConverter
+}
+
+// TestContext returns the Annex context that tests and cases are executed in.
+func (r *Runtime) TestContext() string {
+	if r == nil || r.Context == "" {
+		return defaultContext
+	}
+	return r.Context
+}
+
+// ArtifactSink returns the sink that stores artifacts attached to test and case
+// executions, or nil if none is configured.
+func (r *Runtime) ArtifactSink() ArtifactSink {
+	if r == nil {
+		return nil
+	}
+	return r.Artifacts
+}
+
+// SealSecrets returns a copy of v with its secret fields encrypted. It returns
+// ErrNoSecretKey if v has secret fields and the runtime has no cipher.
+func (r *Runtime) SealSecrets(v any) (any, error) {
+	if has, err := param.HasSecrets(reflect.TypeOf(v)); err != nil || !has {
+		return v, err
+	}
+	if r == nil || r.Secrets == nil {
+		return nil, ErrNoSecretKey
+	}
+	return r.Secrets.Seal(v)
+}
+
+// sealedPayload is the result of sealing a value in a workflow.
+type sealedPayload struct {
+	Payload *common.Payload
+	Err     string
+}
+
+// EncodeSealed encrypts the secret fields of v and encodes it with the data
+// converter. Secrets are encrypted with random nonces, so sealing is recorded
+// with a side effect to keep the workflow deterministic.
+func (r *Runtime) EncodeSealed(ctx workflow.Context, v any) (*common.Payload, error) {
+	has, err := param.HasSecrets(reflect.TypeOf(v))
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return r.Converter().ToPayload(v)
+	}
+	if r == nil || r.Secrets == nil {
+		return nil, ErrNoSecretKey
+	}
+
+	var res sealedPayload
+	err = workflow.SideEffect(ctx, func(workflow.Context) any {
+		sealed, err := r.Secrets.Seal(v)
+		if err != nil {
+			return sealedPayload{Err: err.Error()}
+		}
+		payload, err := r.Converter().ToPayload(sealed)
+		if err != nil {
+			return sealedPayload{Err: err.Error()}
+		}
+		return sealedPayload{Payload: payload}
+	}).Get(&res)
+	if err != nil {
+		return nil, err
+	}
+	if res.Err != "" {
+		return nil, errors.New(res.Err)
+	}
+	return res.Payload, nil
+}
+
+// OpenSecrets decrypts the secret fields of the value ptr points to. The
+// secret values are registered with the redactor so that they are never
+// logged. Values that are not encrypted, such as test inputs entered in Annex,
+// are left as is.
+func (r *Runtime) OpenSecrets(ptr any) error {
+	if r == nil {
+		return nil
+	}
+	if r.Secrets != nil {
+		if err := r.Secrets.Open(ptr); err != nil {
+			return err
+		}
+	}
+	r.Redactor.AddSecrets(param.SecretValues(ptr)...)
+	return nil
+}
+
+// AddSecrets registers secret values that are masked in test and case logs.
+func (r *Runtime) AddSecrets(values ...string) {
+	if r == nil {
+		return
+	}
+	r.Redactor.AddSecrets(values...)
+}
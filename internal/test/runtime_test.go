@@ -0,0 +1,78 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/annexsh/annex-sdk-go/internal/param"
+	"github.com/annexsh/annex-sdk-go/internal/temporal"
+)
+
+type runtimeCreds struct {
+	User     string
+	Password string `annex:"secret"`
+}
+
+func TestRuntime_SealSecrets(t *testing.T) {
+	secrets, err := param.NewSecretCipher([]byte("0123456789abcdef"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		rt      *Runtime
+		v       any
+		wantErr error
+	}{
+		{name: "no secrets without key", rt: &Runtime{}, v: struct{ User string }{User: "admin"}},
+		{name: "nil runtime", rt: nil, v: "plain"},
+		{name: "secrets with key", rt: &Runtime{Secrets: secrets}, v: runtimeCreds{User: "admin", Password: "hunter22"}},
+		{name: "secrets without key", rt: &Runtime{}, v: runtimeCreds{Password: "hunter22"}, wantErr: ErrNoSecretKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealed, err := tt.rt.SealSecrets(tt.v)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			if creds, ok := tt.v.(runtimeCreds); ok {
+				assert.NotEqual(t, creds.Password, sealed.(runtimeCreds).Password)
+				return
+			}
+			assert.Equal(t, tt.v, sealed)
+		})
+	}
+}
+
+func TestRuntime_OpenSecrets(t *testing.T) {
+	secrets, err := param.NewSecretCipher([]byte("0123456789abcdef"))
+	require.NoError(t, err)
+	sealed, err := secrets.Seal(runtimeCreds{Password: "sealed-secret"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		rt    *Runtime
+		creds runtimeCreds
+		want  string
+	}{
+		{name: "sealed", rt: &Runtime{Secrets: secrets}, creds: sealed.(runtimeCreds), want: "sealed-secret"},
+		{name: "plaintext", rt: &Runtime{Secrets: secrets}, creds: runtimeCreds{Password: "plain-secret"}, want: "plain-secret"},
+		{name: "plaintext without key", rt: &Runtime{}, creds: runtimeCreds{Password: "plain-secret"}, want: "plain-secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rt.Redactor = temporal.NewRedactor(nil, nil)
+			require.NoError(t, tt.rt.OpenSecrets(&tt.creds))
+			assert.Equal(t, tt.want, tt.creds.Password)
+
+			msg, _ := tt.rt.Redactor.Redact("password is "+tt.want, nil)
+			assert.Equal(t, "password is "+param.RedactedValue, msg)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package test
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/annexsh/annex-proto/go/gen/annex/tests/v1"
+	temporalsdk "go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// TagsMemoKey is the test workflow memo key holding the static tags declared
+// when the test was registered.
+const TagsMemoKey = "tags"
+
+// TaggedTest returns a test executor that records tags in the workflow memo
+// and, if searchAttribute is not empty, in that keyword list search attribute
+// before executing the test.
+func TaggedTest(tags []string, searchAttribute string, executor TestExecutor) TestExecutor {
+	tags = slices.Compact(slices.Sorted(slices.Values(tags)))
+	return func(ctx workflow.Context, payload *testsv1.Payload, checkpoint *Checkpoint) (*Output, error) {
+		if err := workflow.UpsertMemo(ctx, map[string]any{TagsMemoKey: tags}); err != nil {
+			return nil, fmt.Errorf("failed to set test tags memo: %w", err)
+		}
+		if searchAttribute != "" {
+			key := temporalsdk.NewSearchAttributeKeyKeywordList(searchAttribute)
+			if err := workflow.UpsertTypedSearchAttributes(ctx, key.ValueSet(tags)); err != nil {
+				return nil, fmt.Errorf("failed to set test tags search attribute: %w", err)
+			}
+		}
+		return executor(ctx, payload, checkpoint)
+	}
+}
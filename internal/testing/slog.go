@@ -0,0 +1,96 @@
+package testing
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+
+	tlog "go.temporal.io/sdk/log"
+
+	"github.com/annexsh/annex-sdk-go/internal/temporal"
+)
+
+// slogHandler routes slog records to a test or case logger so that they are
+// published to Annex.
+type slogHandler struct {
+	logger tlog.Logger
+	groups []string
+}
+
+func newSlogLogger(logger tlog.Logger) *slog.Logger {
+	return slog.New(&slogHandler{logger: logger})
+}
+
+// levelLogger is implemented by the test and case loggers.
+type levelLogger interface {
+	Enabled(level temporal.Level) bool
+}
+
+// Enabled reports whether the logger writes or publishes logs of level, so
+// that disabled records are not built.
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	if l, ok := h.logger.(levelLogger); ok {
+		return l.Enabled(temporal.LevelFromSlog(level))
+	}
+	return true
+}
+
+func (h *slogHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	keyvals := h.groupAttrs(attrs)
+
+	switch temporal.LevelFromSlog(r.Level) {
+	case temporal.LevelDebug:
+		h.logger.Debug(r.Message, keyvals...)
+	case temporal.LevelInfo:
+		h.logger.Info(r.Message, keyvals...)
+	case temporal.LevelWarn:
+		h.logger.Warn(r.Message, keyvals...)
+	default:
+		h.logger.Error(r.Message, keyvals...)
+	}
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &slogHandler{
+		logger: tlog.With(h.logger, h.groupAttrs(attrs)...),
+		groups: h.groups,
+	}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{
+		logger: h.logger,
+		groups: append(slices.Clip(h.groups), name),
+	}
+}
+
+// groupAttrs nests attrs in the handler's groups and returns them as keyvals.
+func (h *slogHandler) groupAttrs(attrs []slog.Attr) []any {
+	if len(attrs) == 0 {
+		return nil
+	}
+	if len(h.groups) == 0 {
+		keyvals := make([]any, len(attrs))
+		for i, attr := range attrs {
+			keyvals[i] = attr
+		}
+		return keyvals
+	}
+	group := slog.Attr{Key: h.groups[len(h.groups)-1], Value: slog.GroupValue(attrs...)}
+	for i := len(h.groups) - 2; i >= 0; i-- {
+		group = slog.Attr{Key: h.groups[i], Value: slog.GroupValue(group)}
+	}
+	return []any{group}
+}
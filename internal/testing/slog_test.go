@@ -0,0 +1,94 @@
+package testing
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tlog "go.temporal.io/sdk/log"
+
+	"github.com/annexsh/annex-sdk-go/internal/temporal"
+)
+
+type recordedLog struct {
+	level   temporal.Level
+	msg     string
+	keyvals []any
+}
+
+// recordingLogger records logs at or above min.
+type recordingLogger struct {
+	min     temporal.Level
+	keyvals []any
+	logs    *[]recordedLog
+}
+
+func (l *recordingLogger) Enabled(level temporal.Level) bool {
+	return level.SlogLevel() >= l.min.SlogLevel()
+}
+
+func (l *recordingLogger) record(level temporal.Level, msg string, keyvals []any) {
+	*l.logs = append(*l.logs, recordedLog{level: level, msg: msg, keyvals: append(l.keyvals, keyvals...)})
+}
+
+func (l *recordingLogger) Debug(msg string, keyvals ...any) {
+	l.record(temporal.LevelDebug, msg, keyvals)
+}
+func (l *recordingLogger) Info(msg string, keyvals ...any) {
+	l.record(temporal.LevelInfo, msg, keyvals)
+}
+func (l *recordingLogger) Warn(msg string, keyvals ...any) {
+	l.record(temporal.LevelWarn, msg, keyvals)
+}
+func (l *recordingLogger) Error(msg string, keyvals ...any) {
+	l.record(temporal.LevelError, msg, keyvals)
+}
+
+func (l *recordingLogger) With(keyvals ...any) tlog.Logger {
+	return &recordingLogger{min: l.min, keyvals: append(l.keyvals, keyvals...), logs: l.logs}
+}
+
+func TestSlogHandler_Enabled(t *testing.T) {
+	tests := []struct {
+		name   string
+		min    temporal.Level
+		level  slog.Level
+		wantOK bool
+	}{
+		{name: "debug below info", min: temporal.LevelInfo, level: slog.LevelDebug, wantOK: false},
+		{name: "info at info", min: temporal.LevelInfo, level: slog.LevelInfo, wantOK: true},
+		{name: "between levels rounds down", min: temporal.LevelWarn, level: slog.LevelInfo + 2, wantOK: false},
+		{name: "error above warn", min: temporal.LevelWarn, level: slog.LevelError + 4, wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs []recordedLog
+			logger := newSlogLogger(&recordingLogger{min: tt.min, logs: &logs})
+
+			assert.Equal(t, tt.wantOK, logger.Enabled(context.Background(), tt.level))
+			logger.Log(context.Background(), tt.level, "msg")
+			assert.Equal(t, tt.wantOK, len(logs) == 1)
+		})
+	}
+}
+
+func TestSlogHandler_EnabledWithoutLevels(t *testing.T) {
+	logger := newSlogLogger(tlog.NewStructuredLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	assert.True(t, logger.Enabled(context.Background(), slog.LevelDebug-4))
+}
+
+func TestSlogHandler_Handle(t *testing.T) {
+	var logs []recordedLog
+	logger := newSlogLogger(&recordingLogger{min: temporal.LevelDebug, logs: &logs})
+
+	logger.With("a", 1).WithGroup("g").Warn("msg", "b", 2)
+
+	assert.Equal(t, []recordedLog{{
+		level:   temporal.LevelWarn,
+		msg:     "msg",
+		keyvals: []any{slog.Int("a", 1), slog.Group("g", slog.Int("b", 2))},
+	}}, logs)
+}
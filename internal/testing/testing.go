@@ -3,11 +3,19 @@ package testing
 import (
 	"context"
 	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"slices"
 
 	"github.com/annexsh/annex/test"
 	"github.com/stretchr/testify/assert"
+	"go.temporal.io/api/common/v1"
 	"go.temporal.io/sdk/activity"
+	tlog "go.temporal.io/sdk/log"
 	"go.temporal.io/sdk/workflow"
+
+	sdktest "github.com/annexsh/annex-sdk-go/internal/test"
 )
 
 type Logger interface {
@@ -15,24 +23,61 @@ type Logger interface {
 	Info(msg string, keyvals ...any)
 	Warn(msg string, keyvals ...any)
 	Error(msg string, keyvals ...any)
+	// With returns a logger that prepends every log entry with keyvals.
+	With(keyvals ...any) Logger
+}
+
+type logger struct {
+	tlog.Logger
+}
+
+func (l logger) With(keyvals ...any) Logger {
+	return logger{tlog.With(l.Logger, keyvals...)}
 }
 
 type WorkflowT interface {
 	WorkflowContext() workflow.Context
 	NextCaseExecutionID() test.CaseExecutionID
+	CurrentCaseExecutionID() test.CaseExecutionID
+	CheckpointState() *common.Payload
+	TrackPending(pending *sdktest.Pending)
+	UnresolvedPendings() []*sdktest.Pending
 }
 
 type TestT struct {
 	*CollectT
 	ctx     workflow.Context
-	current test.CaseExecutionID
+	current *test.CaseExecutionID
+	state   *common.Payload
+	pending *[]*sdktest.Pending
 }
 
-func NewT(ctx workflow.Context) *TestT {
-	return &TestT{
+// NewT creates a test T. The checkpoint is nil unless the test is resuming
+// after a continue-as-new.
+func NewT(ctx workflow.Context, checkpoint *sdktest.Checkpoint) *TestT {
+	t := &TestT{
 		CollectT: new(CollectT),
 		ctx:      ctx,
-		current:  0,
+		current:  new(test.CaseExecutionID),
+		pending:  new([]*sdktest.Pending),
+	}
+	if checkpoint != nil {
+		*t.current = checkpoint.LastCaseExecID
+		t.state = checkpoint.State
+	}
+	return t
+}
+
+// Subtest creates a T for part of a test body. Assertion failures are
+// collected separately while case numbering and started cases are shared with
+// the parent.
+func (t *TestT) Subtest() *TestT {
+	return &TestT{
+		CollectT: new(CollectT),
+		ctx:      t.ctx,
+		current:  t.current,
+		state:    t.state,
+		pending:  t.pending,
 	}
 }
 
@@ -41,12 +86,48 @@ func (t *TestT) WorkflowContext() workflow.Context {
 }
 
 func (t *TestT) Logger() Logger {
-	return workflow.GetLogger(t.ctx)
+	return logger{workflow.GetLogger(t.ctx)}
+}
+
+// Attach stores an artifact and records its reference in the test logs.
+func (t *TestT) Attach(name string, contentType string, r io.Reader) error {
+	_, err := sdktest.AttachWorkflowArtifact(t.ctx, name, contentType, r)
+	return err
+}
+
+// Slog returns a slog logger that publishes to the test logs.
+func (t *TestT) Slog() *slog.Logger {
+	return newSlogLogger(workflow.GetLogger(t.ctx))
 }
 
 func (t *TestT) NextCaseExecutionID() test.CaseExecutionID {
-	t.current++
-	return t.current
+	*t.current++
+	return *t.current
+}
+
+func (t *TestT) CurrentCaseExecutionID() test.CaseExecutionID {
+	return *t.current
+}
+
+func (t *TestT) CheckpointState() *common.Payload {
+	return t.state
+}
+
+// TrackPending records a started case until it is resolved.
+func (t *TestT) TrackPending(pending *sdktest.Pending) {
+	*t.pending = append(*t.pending, pending)
+}
+
+// UnresolvedPendings returns the started cases that have not finished.
+func (t *TestT) UnresolvedPendings() []*sdktest.Pending {
+	*t.pending = slices.DeleteFunc(*t.pending, (*sdktest.Pending).IsReady)
+	return slices.Clone(*t.pending)
+}
+
+// ClearCheckpointState drops the checkpoint state once the part of the test
+// body that saved it has resumed, so that later parts don't restore it.
+func (t *TestT) ClearCheckpointState() {
+	t.state = nil
 }
 
 type CaseT struct {
@@ -66,7 +147,36 @@ func (t *CaseT) Context() context.Context {
 }
 
 func (t *CaseT) Logger() Logger {
-	return activity.GetLogger(t.ctx)
+	return logger{activity.GetLogger(t.ctx)}
+}
+
+// Slog returns a slog logger that publishes to the case logs.
+func (t *CaseT) Slog() *slog.Logger {
+	return newSlogLogger(activity.GetLogger(t.ctx))
+}
+
+// Stdout returns a writer that publishes each line written to it as a case
+// log.
+func (t *CaseT) Stdout() io.Writer {
+	return sdktest.CaseOutputFromContext(t.ctx).Stdout
+}
+
+// Stderr returns a writer that publishes each line written to it as a warning
+// case log.
+func (t *CaseT) Stderr() io.Writer {
+	return sdktest.CaseOutputFromContext(t.ctx).Stderr
+}
+
+// StdLogger returns a standard library logger that publishes to the case logs.
+func (t *CaseT) StdLogger() *log.Logger {
+	return log.New(sdktest.CaseOutputFromContext(t.ctx).Log, "", 0)
+}
+
+// Attach stores an artifact and records its reference in the case logs and
+// response.
+func (t *CaseT) Attach(name string, contentType string, r io.Reader) error {
+	_, err := sdktest.AttachArtifact(t.ctx, name, contentType, r)
+	return err
 }
 
 type tHelper interface {
@@ -0,0 +1,148 @@
+package annex
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/annexsh/annex-sdk-go/internal/test"
+)
+
+// LatencySummary describes a distribution of case execution durations.
+type LatencySummary struct {
+	Count int
+	Min   time.Duration
+	Max   time.Duration
+	Mean  time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+}
+
+func (s LatencySummary) String() string {
+	return fmt.Sprintf("n=%d min=%s mean=%s p50=%s p90=%s p95=%s p99=%s max=%s",
+		s.Count, s.Min, s.Mean, s.P50, s.P90, s.P95, s.P99, s.Max)
+}
+
+// LatencyThresholds are upper bounds on a latency distribution. Zero fields
+// are not checked.
+type LatencyThresholds struct {
+	Max  time.Duration
+	Mean time.Duration
+	P50  time.Duration
+	P90  time.Duration
+	P95  time.Duration
+	P99  time.Duration
+}
+
+// RequireLatency waits for the pending cases to succeed and fails the test if
+// the distribution of their durations exceeds any of the thresholds or there
+// are no pending cases.
+func RequireLatency(t TestT, thresholds LatencyThresholds, pendings ...*test.Pending) LatencySummary {
+	summary := summarizeLatency(requireDurations(t, pendings))
+	RequireLatencySummary(t, summary, thresholds)
+	return summary
+}
+
+// RequireLatencySummary fails the test if summary exceeds any of the
+// thresholds, e.g. the latency of a LoadResult.
+func RequireLatencySummary(t TestT, summary LatencySummary, thresholds LatencyThresholds) {
+	checks := []struct {
+		name      string
+		actual    time.Duration
+		threshold time.Duration
+	}{
+		{"max", summary.Max, thresholds.Max},
+		{"mean", summary.Mean, thresholds.Mean},
+		{"p50", summary.P50, thresholds.P50},
+		{"p90", summary.P90, thresholds.P90},
+		{"p95", summary.P95, thresholds.P95},
+		{"p99", summary.P99, thresholds.P99},
+	}
+
+	var violations []string
+	for _, c := range checks {
+		if c.threshold > 0 && c.actual > c.threshold {
+			violations = append(violations, fmt.Sprintf("%s %s exceeds %s", c.name, c.actual, c.threshold))
+		}
+	}
+
+	if len(violations) > 0 {
+		require.Failf(t, "case latency threshold exceeded", "%v\nlatency: %s", violations, summary)
+	}
+}
+
+// RequirePercentile waits for the pending cases to succeed and fails the test
+// if the p-th percentile (0-100] of their durations exceeds threshold or there
+// are no pending cases.
+func RequirePercentile(t TestT, p float64, threshold time.Duration, pendings ...*test.Pending) time.Duration {
+	if p <= 0 || p > 100 {
+		panic(fmt.Sprintf("invalid percentile: %v", p))
+	}
+
+	durations := requireDurations(t, pendings)
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+
+	actual := percentile(sorted, p)
+	if actual > threshold {
+		require.Failf(t, "case latency threshold exceeded", "p%v %s exceeds %s\nlatency: %s",
+			p, actual, threshold, summarizeLatency(durations))
+	}
+	return actual
+}
+
+func requireDurations(t TestT, pendings []*test.Pending) []time.Duration {
+	// No latency can be measured, so no threshold would ever fail.
+	require.NotEmpty(t, pendings, "no pending cases to measure the latency of")
+	ctx := getWorkflowT(t).WorkflowContext()
+	durations := make([]time.Duration, len(pendings))
+	for i, pending := range pendings {
+		var res test.CaseResponse[any]
+		err := test.GetPendingError(pending)
+		require.NoError(t, err)
+		err = test.GetPendingFuture(pending).Get(ctx, &res)
+		require.NoError(t, err)
+		durations[i] = res.Duration
+	}
+	return durations
+}
+
+func summarizeLatency(durations []time.Duration) LatencySummary {
+	if len(durations) == 0 {
+		return LatencySummary{}
+	}
+
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+
+	return LatencySummary{
+		Count: len(sorted),
+		Min:   sorted[0],
+		Max:   sorted[len(sorted)-1],
+		Mean:  total / time.Duration(len(sorted)),
+		P50:   percentile(sorted, 50),
+		P90:   percentile(sorted, 90),
+		P95:   percentile(sorted, 95),
+		P99:   percentile(sorted, 99),
+	}
+}
+
+// percentile returns the nearest-rank percentile p of sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	rank = min(max(rank, 1), len(sorted))
+	return sorted[rank-1]
+}
@@ -0,0 +1,113 @@
+package annex
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPercentile(t *testing.T) {
+	durations := func(ms ...int) []time.Duration {
+		ds := make([]time.Duration, len(ms))
+		for i, m := range ms {
+			ds[i] = time.Duration(m) * time.Millisecond
+		}
+		return ds
+	}
+
+	tests := []struct {
+		name   string
+		sorted []time.Duration
+		p      float64
+		want   time.Duration
+	}{
+		{name: "empty", sorted: nil, p: 50, want: 0},
+		{name: "single sample p0", sorted: durations(7), p: 0, want: 7 * time.Millisecond},
+		{name: "single sample p50", sorted: durations(7), p: 50, want: 7 * time.Millisecond},
+		{name: "single sample p100", sorted: durations(7), p: 100, want: 7 * time.Millisecond},
+		{name: "p0 is min", sorted: durations(1, 2, 3, 4), p: 0, want: time.Millisecond},
+		{name: "p100 is max", sorted: durations(1, 2, 3, 4), p: 100, want: 4 * time.Millisecond},
+		{name: "nearest rank", sorted: durations(1, 2, 3, 4), p: 50, want: 2 * time.Millisecond},
+		{name: "rounds rank up", sorted: durations(1, 2, 3, 4), p: 51, want: 3 * time.Millisecond},
+		{name: "p99 of ten", sorted: durations(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), p: 99, want: 10 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, percentile(tt.sorted, tt.p))
+		})
+	}
+}
+
+func TestSummarizeLatency(t *testing.T) {
+	ms := time.Millisecond
+
+	tests := []struct {
+		name      string
+		durations []time.Duration
+		want      LatencySummary
+	}{
+		{
+			name: "empty",
+			want: LatencySummary{},
+		},
+		{
+			name:      "single sample",
+			durations: []time.Duration{5 * ms},
+			want: LatencySummary{
+				Count: 1, Min: 5 * ms, Max: 5 * ms, Mean: 5 * ms,
+				P50: 5 * ms, P90: 5 * ms, P95: 5 * ms, P99: 5 * ms,
+			},
+		},
+		{
+			name:      "unsorted",
+			durations: []time.Duration{40 * ms, 10 * ms, 30 * ms, 20 * ms},
+			want: LatencySummary{
+				Count: 4, Min: 10 * ms, Max: 40 * ms, Mean: 25 * ms,
+				P50: 20 * ms, P90: 40 * ms, P95: 40 * ms, P99: 40 * ms,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			durations := append([]time.Duration(nil), tt.durations...)
+			assert.Equal(t, tt.want, summarizeLatency(durations))
+			assert.Equal(t, tt.durations, durations, "durations must not be sorted in place")
+		})
+	}
+}
+
+func TestRequireLatency_NoPendings(t *testing.T) {
+	tests := []struct {
+		name    string
+		require func(t TestT)
+	}{
+		{
+			name: "latency",
+			require: func(t TestT) {
+				RequireLatency(t, LatencyThresholds{Max: time.Second})
+			},
+		},
+		{
+			name: "percentile",
+			require: func(t TestT) {
+				RequirePercentile(t, 99, time.Second)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs []string
+			err := executeTestBody(t, func(t TestT) {
+				tt.require(&errorRecordingT{TestT: t, WorkflowT: getWorkflowT(t), errs: &errs})
+			})
+			require.Error(t, err)
+			require.Len(t, errs, 1)
+			assert.Contains(t, errs[0], "no pending cases to measure the latency of")
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package annex
+
+import (
+	"time"
+
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/annexsh/annex-sdk-go/internal/test"
+)
+
+// LoadOptions controls how Load fans out case executions. At least one of
+// Iterations or Duration must be set.
+type LoadOptions struct {
+	// Iterations is the total number of case executions to start. Zero means
+	// no limit, in which case Duration bounds the load.
+	Iterations int
+	// Concurrency is the maximum number of case executions in flight. It
+	// defaults to 1.
+	Concurrency int
+	// RatePerSecond is the maximum number of case executions started per
+	// second. Zero means no rate limit.
+	RatePerSecond float64
+	// Duration stops starting new case executions once elapsed. Zero means no
+	// time limit.
+	Duration time.Duration
+}
+
+// LoadResult summarizes the case executions started by Load.
+type LoadResult struct {
+	Iterations int
+	Errors     int
+	Elapsed    time.Duration
+	// Throughput is the number of case executions that finished, successfully
+	// or not, per second. Executions that failed to start are not counted.
+	Throughput float64
+	// ErrorRate is the fraction of case executions that failed.
+	ErrorRate float64
+	// Latency is computed from the successful case executions.
+	Latency LatencySummary
+}
+
+// Load executes a registered case repeatedly from the test workflow according
+// to opts and waits for every execution to finish. Each execution is recorded
+// as its own case execution.
+func Load(t TestT, caseFunc any, opts LoadOptions, startOpts ...StartCaseOption) *LoadResult {
+	if opts.Iterations <= 0 && opts.Duration <= 0 {
+		panic("load iterations or duration must be set")
+	}
+
+	ctx := getWorkflowT(t).WorkflowContext()
+
+	concurrency := max(opts.Concurrency, 1)
+	var interval time.Duration
+	if opts.RatePerSecond > 0 {
+		interval = time.Duration(float64(time.Second) / opts.RatePerSecond)
+	}
+
+	start := workflow.Now(ctx)
+	var deadline time.Time
+	if opts.Duration > 0 {
+		deadline = start.Add(opts.Duration)
+	}
+
+	res := &LoadResult{}
+	var durations []time.Duration
+	inFlight := 0
+	finished := 0
+
+	selector := workflow.NewSelector(ctx)
+	onFinished := func(f workflow.Future) {
+		inFlight--
+		finished++
+		var caseRes test.CaseResponse[any]
+		if err := f.Get(ctx, &caseRes); err != nil {
+			res.Errors++
+			return
+		}
+		durations = append(durations, caseRes.Duration)
+	}
+
+	nextStart := start
+	for opts.Iterations <= 0 || res.Iterations < opts.Iterations {
+		for inFlight >= concurrency {
+			selector.Select(ctx)
+		}
+
+		now := workflow.Now(ctx)
+		if now.Before(nextStart) {
+			if err := workflow.Sleep(ctx, nextStart.Sub(now)); err != nil {
+				break
+			}
+			now = workflow.Now(ctx)
+		}
+		if !deadline.IsZero() && !now.Before(deadline) {
+			break
+		}
+		nextStart = now.Add(interval)
+
+		pending := StartCase(t, caseFunc, startOpts...)
+		res.Iterations++
+		if err := test.GetPendingError(pending); err != nil {
+			res.Errors++
+			continue
+		}
+		inFlight++
+		selector.AddFuture(test.GetPendingFuture(pending), onFinished)
+	}
+
+	for inFlight > 0 {
+		selector.Select(ctx)
+	}
+
+	res.Elapsed = workflow.Now(ctx).Sub(start)
+	if res.Elapsed > 0 {
+		res.Throughput = float64(finished) / res.Elapsed.Seconds()
+	}
+	if res.Iterations > 0 {
+		res.ErrorRate = float64(res.Errors) / float64(res.Iterations)
+	}
+	res.Latency = summarizeLatency(durations)
+
+	return res
+}
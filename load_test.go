@@ -0,0 +1,127 @@
+package annex
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/workflow"
+)
+
+// executeTestBody runs body as a test workflow with caseFuncs registered as
+// cases.
+func executeTestBody(t *testing.T, body func(t TestT), caseFuncs ...func(t CaseT)) error {
+	tester := &simpleTest{test: body}
+	env := newTestWorkflowEnv(t, newTestRuntime())
+	env.RegisterWorkflowWithOptions(tester.workflow, workflow.RegisterOptions{Name: testWorkflowName})
+	for _, caseFn := range caseFuncs {
+		c := &simpleCase{caseFn: caseFn}
+		env.RegisterActivityWithOptions(c.activity, activity.RegisterOptions{Name: c.name()})
+	}
+	env.ExecuteWorkflow(testWorkflowName, nil, nil)
+	require.True(t, env.IsWorkflowCompleted())
+	return env.GetWorkflowError()
+}
+
+func TestLoad(t *testing.T) {
+	var calls, inFlight, maxInFlight atomic.Int64
+	loadCase := func(t CaseT) {
+		// Every third execution fails.
+		n := calls.Add(1)
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			prev := maxInFlight.Load()
+			if current <= prev || maxInFlight.CompareAndSwap(prev, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		require.NotZero(t, n%3, "failed")
+	}
+
+	tests := []struct {
+		name            string
+		opts            LoadOptions
+		startOpts       []StartCaseOption
+		wantIterations  int
+		wantErrors      int
+		wantMaxInFlight int64
+		wantMinElapsed  time.Duration
+	}{
+		{
+			name:            "iterations",
+			opts:            LoadOptions{Iterations: 3},
+			wantIterations:  3,
+			wantErrors:      1,
+			wantMaxInFlight: 1,
+		},
+		{
+			name:            "concurrency",
+			opts:            LoadOptions{Iterations: 6, Concurrency: 2},
+			wantIterations:  6,
+			wantErrors:      2,
+			wantMaxInFlight: 2,
+		},
+		{
+			name:            "duration",
+			opts:            LoadOptions{Duration: 10 * time.Second, RatePerSecond: 1},
+			wantIterations:  10,
+			wantErrors:      3,
+			wantMaxInFlight: 1,
+			wantMinElapsed:  9 * time.Second,
+		},
+		{
+			name:            "iterations before duration",
+			opts:            LoadOptions{Iterations: 2, Duration: time.Hour, RatePerSecond: 1},
+			wantIterations:  2,
+			wantMaxInFlight: 1,
+			wantMinElapsed:  time.Second,
+		},
+		{
+			name:           "failed to start",
+			opts:           LoadOptions{Iterations: 2},
+			startOpts:      []StartCaseOption{WithInput(make(chan int))},
+			wantIterations: 2,
+			wantErrors:     2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls.Store(0)
+			maxInFlight.Store(0)
+
+			var res *LoadResult
+			err := executeTestBody(t, func(t TestT) {
+				res = Load(t, loadCase, tt.opts, tt.startOpts...)
+			}, loadCase)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantIterations, res.Iterations)
+			assert.Equal(t, tt.wantErrors, res.Errors)
+			assert.Equal(t, tt.wantMaxInFlight, maxInFlight.Load())
+			assert.InDelta(t, float64(tt.wantErrors)/float64(tt.wantIterations), res.ErrorRate, 1e-9)
+			assert.GreaterOrEqual(t, res.Elapsed, tt.wantMinElapsed)
+
+			// Executions that failed to start never finish, and failed
+			// executions have no latency. The workflow clock only advances
+			// while the load waits for its rate limit.
+			if res.Elapsed > 0 {
+				assert.InDelta(t, float64(calls.Load())/res.Elapsed.Seconds(), res.Throughput, 1e-9)
+			} else {
+				assert.Zero(t, res.Throughput)
+			}
+			assert.Equal(t, tt.wantIterations-tt.wantErrors, res.Latency.Count)
+		})
+	}
+}
+
+func TestLoad_RequiresBound(t *testing.T) {
+	assert.PanicsWithValue(t, "load iterations or duration must be set", func() {
+		Load(nil, replayCase, LoadOptions{Concurrency: 2})
+	})
+}
@@ -0,0 +1,222 @@
+package annex
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Matrix maps the dimensions of a test parameter struct to the values each
+// dimension takes. A dimension is identified by the field's json name or, if
+// it has none, its Go name.
+type Matrix map[string][]any
+
+type matrixOptions struct {
+	include []map[string]any
+	exclude []map[string]any
+	tags    []string
+}
+
+type MatrixOption func(opts *matrixOptions)
+
+// WithMatrixInclude adds a combination to the matrix in addition to the
+// cartesian product. Fields not present in values are left as the zero value.
+func WithMatrixInclude(values map[string]any) MatrixOption {
+	return func(opts *matrixOptions) {
+		opts.include = append(opts.include, values)
+	}
+}
+
+// WithMatrixExclude removes every combination whose dimensions match all of
+// values.
+func WithMatrixExclude(values map[string]any) MatrixOption {
+	return func(opts *matrixOptions) {
+		opts.exclude = append(opts.exclude, values)
+	}
+}
+
+// WithMatrixTags declares static tags for the matrix test and every
+// combination. See WithTags.
+func WithMatrixTags(tags ...string) MatrixOption {
+	return func(opts *matrixOptions) {
+		opts.tags = append(opts.tags, tags...)
+	}
+}
+
+// RegisterMatrixTest registers a test for every combination of the matrix
+// dimensions. Combinations are registered as table test rows named by their
+// dimension values in field order, e.g. "<name>/browser=chrome,region=eu", and
+// name runs every combination. It panics if the matrix does not match P.
+func RegisterMatrixTest[P any](runner *TestSuiteRunner, name string, matrix Matrix, test func(t TestT, param P), opts ...MatrixOption) {
+	var options matrixOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	rows, err := expandMatrix[P](matrix, options)
+	if err != nil {
+		panic(fmt.Sprintf("invalid matrix for test %s: %v", name, err))
+	}
+
+	RegisterTableTest(runner, name, rows, test, WithTags(options.tags...))
+}
+
+type matrixField struct {
+	name  string
+	index int
+}
+
+type matrixCombination map[string]any
+
+func expandMatrix[P any](matrix Matrix, opts matrixOptions) (map[string]P, error) {
+	paramType := reflect.TypeFor[P]()
+	if paramType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("test parameter must be a struct: got %s", paramType)
+	}
+
+	fields := matrixFields(paramType)
+	checkFields := func(combo map[string]any) error {
+		for key := range combo {
+			if !slices.ContainsFunc(fields, func(f matrixField) bool { return f.name == key }) {
+				return fmt.Errorf("dimension %q is not a field of %s", key, paramType)
+			}
+		}
+		return nil
+	}
+
+	if len(matrix) == 0 {
+		return nil, fmt.Errorf("matrix must have at least one dimension")
+	}
+	for dim := range matrix {
+		if !slices.ContainsFunc(fields, func(f matrixField) bool { return f.name == dim }) {
+			return nil, fmt.Errorf("dimension %q is not a field of %s", dim, paramType)
+		}
+	}
+
+	var dims []matrixField
+	for _, f := range fields {
+		if _, ok := matrix[f.name]; ok {
+			dims = append(dims, f)
+		}
+	}
+
+	combos := []matrixCombination{{}}
+	for _, dim := range dims {
+		values := matrix[dim.name]
+		if len(values) == 0 {
+			return nil, fmt.Errorf("dimension %q has no values", dim.name)
+		}
+		var next []matrixCombination
+		for _, combo := range combos {
+			for _, v := range values {
+				c := make(matrixCombination, len(combo)+1)
+				for k, cv := range combo {
+					c[k] = cv
+				}
+				c[dim.name] = v
+				next = append(next, c)
+			}
+		}
+		combos = next
+	}
+
+	for _, exc := range opts.exclude {
+		if len(exc) == 0 {
+			return nil, fmt.Errorf("exclude must set at least one dimension")
+		}
+		if err := checkFields(exc); err != nil {
+			return nil, err
+		}
+	}
+	combos = slices.DeleteFunc(combos, func(c matrixCombination) bool {
+		return slices.ContainsFunc(opts.exclude, c.matches)
+	})
+	for _, inc := range opts.include {
+		if len(inc) == 0 {
+			return nil, fmt.Errorf("include must set at least one dimension")
+		}
+		if err := checkFields(inc); err != nil {
+			return nil, err
+		}
+		combos = append(combos, inc)
+	}
+
+	rows := make(map[string]P, len(combos))
+	for _, combo := range combos {
+		var param P
+		val := reflect.ValueOf(&param).Elem()
+		var nameParts []string
+		for _, f := range fields {
+			v, ok := combo[f.name]
+			if !ok {
+				continue
+			}
+			if err := setMatrixField(val.Field(f.index), v); err != nil {
+				return nil, fmt.Errorf("dimension %q: %w", f.name, err)
+			}
+			nameParts = append(nameParts, fmt.Sprintf("%s=%v", f.name, v))
+		}
+		// Values are named with %v, so distinct values such as a pointer and
+		// the string it points to may produce the same name.
+		rowName := strings.Join(nameParts, ",")
+		if existing, ok := rows[rowName]; ok && !reflect.DeepEqual(existing, param) {
+			return nil, fmt.Errorf("several combinations are named %q", rowName)
+		}
+		rows[rowName] = param
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("every combination is excluded")
+	}
+
+	return rows, nil
+}
+
+func (c matrixCombination) matches(rule map[string]any) bool {
+	for k, v := range rule {
+		cv, ok := c[k]
+		if !ok || !reflect.DeepEqual(cv, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func matrixFields(t reflect.Type) []matrixField {
+	var fields []matrixField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, _, _ := strings.Cut(f.Tag.Get("json"), ","); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			name = tag
+		}
+		fields = append(fields, matrixField{name: name, index: i})
+	}
+	return fields
+}
+
+func setMatrixField(field reflect.Value, value any) error {
+	v := reflect.ValueOf(value)
+	switch {
+	case !v.IsValid():
+		field.SetZero()
+	case v.Type().AssignableTo(field.Type()):
+		field.Set(v)
+	case v.Type().ConvertibleTo(field.Type()) &&
+		(v.Kind() == field.Kind() || (isNumericKind(v.Kind()) && isNumericKind(field.Kind()))):
+		field.Set(v.Convert(field.Type()))
+	default:
+		return fmt.Errorf("value %v of type %s cannot be assigned to %s", value, v.Type(), field.Type())
+	}
+	return nil
+}
+
+func isNumericKind(k reflect.Kind) bool {
+	return (k >= reflect.Int && k <= reflect.Uint64) || k == reflect.Float32 || k == reflect.Float64
+}
@@ -0,0 +1,142 @@
+package annex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type matrixParam struct {
+	Browser string `json:"browser"`
+	Region  string `json:"region"`
+	Retries int
+	Extra   any `json:"extra"`
+}
+
+func TestExpandMatrix(t *testing.T) {
+	tests := []struct {
+		name    string
+		matrix  Matrix
+		opts    []MatrixOption
+		want    map[string]matrixParam
+		wantErr string
+	}{
+		{
+			name:   "cartesian product",
+			matrix: Matrix{"browser": {"chrome", "firefox"}, "region": {"eu", "us"}},
+			want: map[string]matrixParam{
+				"browser=chrome,region=eu":  {Browser: "chrome", Region: "eu"},
+				"browser=chrome,region=us":  {Browser: "chrome", Region: "us"},
+				"browser=firefox,region=eu": {Browser: "firefox", Region: "eu"},
+				"browser=firefox,region=us": {Browser: "firefox", Region: "us"},
+			},
+		},
+		{
+			name:   "numeric conversion",
+			matrix: Matrix{"Retries": {1, int64(2)}},
+			want: map[string]matrixParam{
+				"Retries=1": {Retries: 1},
+				"Retries=2": {Retries: 2},
+			},
+		},
+		{
+			name:   "exclude",
+			matrix: Matrix{"browser": {"chrome", "firefox"}, "region": {"eu", "us"}},
+			opts:   []MatrixOption{WithMatrixExclude(map[string]any{"browser": "firefox", "region": "us"})},
+			want: map[string]matrixParam{
+				"browser=chrome,region=eu":  {Browser: "chrome", Region: "eu"},
+				"browser=chrome,region=us":  {Browser: "chrome", Region: "us"},
+				"browser=firefox,region=eu": {Browser: "firefox", Region: "eu"},
+			},
+		},
+		{
+			name:   "include",
+			matrix: Matrix{"browser": {"chrome"}},
+			opts:   []MatrixOption{WithMatrixInclude(map[string]any{"browser": "safari", "region": "us"})},
+			want: map[string]matrixParam{
+				"browser=chrome":           {Browser: "chrome"},
+				"browser=safari,region=us": {Browser: "safari", Region: "us"},
+			},
+		},
+		{
+			name:   "include existing combination",
+			matrix: Matrix{"browser": {"chrome"}},
+			opts:   []MatrixOption{WithMatrixInclude(map[string]any{"browser": "chrome"})},
+			want: map[string]matrixParam{
+				"browser=chrome": {Browser: "chrome"},
+			},
+		},
+		{
+			name:    "empty matrix",
+			matrix:  Matrix{},
+			wantErr: "matrix must have at least one dimension",
+		},
+		{
+			name:    "unknown dimension",
+			matrix:  Matrix{"os": {"linux"}},
+			wantErr: `dimension "os" is not a field`,
+		},
+		{
+			name:    "dimension without values",
+			matrix:  Matrix{"browser": {}},
+			wantErr: `dimension "browser" has no values`,
+		},
+		{
+			name:    "unknown exclude dimension",
+			matrix:  Matrix{"browser": {"chrome"}},
+			opts:    []MatrixOption{WithMatrixExclude(map[string]any{"brwoser": "chrome"})},
+			wantErr: `dimension "brwoser" is not a field`,
+		},
+		{
+			name:    "empty exclude",
+			matrix:  Matrix{"browser": {"chrome"}},
+			opts:    []MatrixOption{WithMatrixExclude(map[string]any{})},
+			wantErr: "exclude must set at least one dimension",
+		},
+		{
+			name:    "unknown include dimension",
+			matrix:  Matrix{"browser": {"chrome"}},
+			opts:    []MatrixOption{WithMatrixInclude(map[string]any{"os": "linux"})},
+			wantErr: `dimension "os" is not a field`,
+		},
+		{
+			name:    "every combination excluded",
+			matrix:  Matrix{"browser": {"chrome"}},
+			opts:    []MatrixOption{WithMatrixExclude(map[string]any{"browser": "chrome"})},
+			wantErr: "every combination is excluded",
+		},
+		{
+			name:    "duplicate row names",
+			matrix:  Matrix{"extra": {"1", 1}},
+			wantErr: `several combinations are named "extra=1"`,
+		},
+		{
+			name:    "unassignable value",
+			matrix:  Matrix{"Retries": {"three"}},
+			wantErr: `dimension "Retries"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var options matrixOptions
+			for _, opt := range tt.opts {
+				opt(&options)
+			}
+
+			got, err := expandMatrix[matrixParam](tt.matrix, options)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExpandMatrix_NonStructParam(t *testing.T) {
+	_, err := expandMatrix[string](Matrix{"a": {1}}, matrixOptions{})
+	require.ErrorContains(t, err, "test parameter must be a struct")
+}
@@ -0,0 +1,74 @@
+package annex
+
+import (
+	"context"
+	"errors"
+
+	"github.com/annexsh/annex/test"
+	"github.com/stretchr/testify/require"
+
+	sdktest "github.com/annexsh/annex-sdk-go/internal/test"
+)
+
+// SetOutput sets the final output of the test, e.g. the ID of a created order
+// or the URL of a provisioned environment. It is stored as the test workflow
+// result, which is encoded with the runner data converter, and can be read
+// with GetTestResult. It is also stored in the workflow memo under "output"
+// with its secret fields redacted. Setting the output again replaces it.
+func SetOutput(t TestT, output any) {
+	err := sdktest.SetOutput(getWorkflowT(t).WorkflowContext(), output)
+	require.NoError(t, err, "failed to set test output")
+}
+
+// Annotate adds a key/value annotation to the test. Annotations are stored in
+// the test workflow result and in the workflow memo under "annotations".
+// Annotating an existing key replaces its value.
+func Annotate(t TestT, key string, value string) {
+	err := sdktest.Annotate(getWorkflowT(t).WorkflowContext(), key, value)
+	require.NoError(t, err, "failed to annotate test")
+}
+
+// TestResult holds the output and annotations of a finished test execution.
+type TestResult struct {
+	Annotations map[string]string
+	output      *sdktest.Output
+	runtime     *sdktest.Runtime
+}
+
+// HasOutput reports whether the test set an output.
+func (r *TestResult) HasOutput() bool {
+	return r.output != nil && r.output.Output != nil
+}
+
+// Output decodes the test output into the value outputPtr points to.
+func (r *TestResult) Output(outputPtr any) error {
+	if !r.HasOutput() {
+		return errors.New("test did not set an output")
+	}
+	return sdktest.DecodeOutput(r.runtime, r.output.Output, outputPtr)
+}
+
+// GetTestResult waits for a test execution to finish and returns its output
+// and annotations. An error is returned if the test failed.
+func (w *TestSuiteRunner) GetTestResult(ctx context.Context, testExecutionID string) (*TestResult, error) {
+	id, err := test.ParseTestExecutionID(testExecutionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var out *sdktest.Output
+	// An empty run ID follows the execution across continue-as-new runs.
+	if err = w.client.GetWorkflow(ctx, id.WorkflowID(), "").Get(ctx, &out); err != nil {
+		return nil, err
+	}
+
+	res := &TestResult{
+		Annotations: map[string]string{},
+		output:      out,
+		runtime:     w.runtime,
+	}
+	if out != nil && out.Annotations != nil {
+		res.Annotations = out.Annotations
+	}
+	return res, nil
+}
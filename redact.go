@@ -0,0 +1,21 @@
+package annex
+
+import (
+	"github.com/annexsh/annex-sdk-go/internal/test"
+)
+
+// RegisterSecret masks values wherever they appear in the logs of every test
+// and case run by the runner, e.g. a token issued during the case. Values
+// shorter than 4 characters are ignored and only the 1024 most recently
+// registered values are masked. Secret param fields are registered when a case
+// decodes its param.
+func RegisterSecret(t CaseT, values ...string) {
+	test.RuntimeFromContext(t.Context()).AddSecrets(values...)
+}
+
+// RegisterSecret masks values wherever they appear in the logs of every test
+// and case run by the runner. Values shorter than 4 characters are ignored.
+// Unlike the values registered by cases, they are never forgotten.
+func (w *TestSuiteRunner) RegisterSecret(values ...string) {
+	w.runtime.Redactor.AddPermanentSecrets(values...)
+}
@@ -0,0 +1,552 @@
+package annex
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strconv"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/annexsh/annex-proto/go/gen/annex/tests/v1"
+	"github.com/annexsh/annex/log"
+	annextest "github.com/annexsh/annex/test"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/failure/v1"
+	"go.temporal.io/api/history/v1"
+	"go.temporal.io/api/taskqueue/v1"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	tlog "go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/annexsh/annex-sdk-go/internal/name"
+	"github.com/annexsh/annex-sdk-go/internal/param"
+	"github.com/annexsh/annex-sdk-go/internal/temporal"
+	"github.com/annexsh/annex-sdk-go/internal/test"
+)
+
+const replayTaskQueue = "annex"
+
+// sideEffectMarker is the marker the SDK records for workflow.SideEffect.
+const sideEffectMarker = "SideEffect"
+
+// localActivityMarker is the marker the SDK records for a local activity, such
+// as a flush of buffered workflow logs.
+const localActivityMarker = "LocalActivity"
+
+// logFlushActivity is the activity type of TestLogActivity.PublishBatch.
+const logFlushActivity = "PublishBatch"
+
+func replayCase(CaseT) {}
+
+type replayParam struct {
+	Name string `json:"name"`
+}
+
+type replaySecretParam struct {
+	User     string `json:"user"`
+	Password string `json:"password" annex:"secret"`
+}
+
+// replaySealedPayload mirrors the value recorded by Runtime.EncodeSealed.
+type replaySealedPayload struct {
+	Payload *common.Payload
+	Err     string
+}
+
+// historyBuilder builds the event history of a test workflow run so that it
+// can be replayed without a Temporal server. Every case is started and
+// completed in its own workflow task.
+type historyBuilder struct {
+	t      *testing.T
+	dc     converter.DataConverter
+	events []*history.HistoryEvent
+	// workflowTaskCompleted is the id of the last workflow task completed
+	// event, which commands are recorded against.
+	workflowTaskCompleted int64
+	sideEffectID          int64
+	localActivityID       int
+}
+
+// replayLocalActivityData mirrors the data the SDK records in a local
+// activity marker.
+type replayLocalActivityData struct {
+	ActivityID   string
+	ActivityType string
+	ReplayTime   time.Time
+	Attempt      int32
+}
+
+func newHistoryBuilder(t *testing.T, workflowType string, args ...any) *historyBuilder {
+	b := &historyBuilder{t: t, dc: converter.GetDefaultDataConverter()}
+	b.add(enums.EVENT_TYPE_WORKFLOW_EXECUTION_STARTED, func(e *history.HistoryEvent) {
+		e.Attributes = &history.HistoryEvent_WorkflowExecutionStartedEventAttributes{
+			WorkflowExecutionStartedEventAttributes: &history.WorkflowExecutionStartedEventAttributes{
+				WorkflowType:        &common.WorkflowType{Name: workflowType},
+				TaskQueue:           &taskqueue.TaskQueue{Name: replayTaskQueue},
+				Input:               b.payloads(args...),
+				WorkflowTaskTimeout: durationpb.New(10 * time.Second),
+			},
+		}
+	})
+	b.workflowTask()
+	return b
+}
+
+func (b *historyBuilder) add(eventType enums.EventType, setAttributes func(e *history.HistoryEvent)) int64 {
+	e := &history.HistoryEvent{
+		EventId:   int64(len(b.events) + 1),
+		EventTime: timestamppb.Now(),
+		EventType: eventType,
+	}
+	setAttributes(e)
+	b.events = append(b.events, e)
+	return e.EventId
+}
+
+func (b *historyBuilder) payloads(values ...any) *common.Payloads {
+	payloads, err := b.dc.ToPayloads(values...)
+	require.NoError(b.t, err)
+	return payloads
+}
+
+func (b *historyBuilder) workflowTask() {
+	scheduled := b.add(enums.EVENT_TYPE_WORKFLOW_TASK_SCHEDULED, func(e *history.HistoryEvent) {
+		e.Attributes = &history.HistoryEvent_WorkflowTaskScheduledEventAttributes{
+			WorkflowTaskScheduledEventAttributes: &history.WorkflowTaskScheduledEventAttributes{
+				TaskQueue:           &taskqueue.TaskQueue{Name: replayTaskQueue},
+				StartToCloseTimeout: durationpb.New(10 * time.Second),
+			},
+		}
+	})
+	started := b.add(enums.EVENT_TYPE_WORKFLOW_TASK_STARTED, func(e *history.HistoryEvent) {
+		e.Attributes = &history.HistoryEvent_WorkflowTaskStartedEventAttributes{
+			WorkflowTaskStartedEventAttributes: &history.WorkflowTaskStartedEventAttributes{
+				ScheduledEventId: scheduled,
+			},
+		}
+	})
+	b.workflowTaskCompleted = b.add(enums.EVENT_TYPE_WORKFLOW_TASK_COMPLETED, func(e *history.HistoryEvent) {
+		e.Attributes = &history.HistoryEvent_WorkflowTaskCompletedEventAttributes{
+			WorkflowTaskCompletedEventAttributes: &history.WorkflowTaskCompletedEventAttributes{
+				ScheduledEventId: scheduled,
+				StartedEventId:   started,
+			},
+		}
+	})
+}
+
+// sideEffect records the result of a workflow.SideEffect call.
+func (b *historyBuilder) sideEffect(result any) *historyBuilder {
+	b.sideEffectID++
+	b.add(enums.EVENT_TYPE_MARKER_RECORDED, func(e *history.HistoryEvent) {
+		e.Attributes = &history.HistoryEvent_MarkerRecordedEventAttributes{
+			MarkerRecordedEventAttributes: &history.MarkerRecordedEventAttributes{
+				MarkerName: sideEffectMarker,
+				Details: map[string]*common.Payloads{
+					"side-effect-id": b.payloads(b.sideEffectID),
+					"data":           b.payloads(result),
+				},
+				WorkflowTaskCompletedEventId: b.workflowTaskCompleted,
+			},
+		}
+	})
+	return b
+}
+
+// flushLogs records a flush of buffered workflow logs that published n logs,
+// or that failed if failed is set.
+func (b *historyBuilder) flushLogs(n int, failed bool) *historyBuilder {
+	b.localActivityID++
+	details := map[string]*common.Payloads{
+		"data": b.payloads(replayLocalActivityData{
+			ActivityID:   strconv.Itoa(b.localActivityID),
+			ActivityType: logFlushActivity,
+			ReplayTime:   time.Now(),
+			Attempt:      1,
+		}),
+	}
+	var flushFailure *failure.Failure
+	if failed {
+		flushFailure = &failure.Failure{Message: "logs not published"}
+	} else {
+		res := temporal.TestLogBatchResponse{LogIDs: make([]uuid.UUID, n)}
+		for i := range res.LogIDs {
+			res.LogIDs[i] = uuid.New()
+		}
+		details["result"] = b.payloads(res)
+	}
+	b.add(enums.EVENT_TYPE_MARKER_RECORDED, func(e *history.HistoryEvent) {
+		e.Attributes = &history.HistoryEvent_MarkerRecordedEventAttributes{
+			MarkerRecordedEventAttributes: &history.MarkerRecordedEventAttributes{
+				MarkerName:                   localActivityMarker,
+				Details:                      details,
+				Failure:                      flushFailure,
+				WorkflowTaskCompletedEventId: b.workflowTaskCompleted,
+			},
+		}
+	})
+	return b
+}
+
+// completeCase records the case execution id run by caseFunc completing with
+// result.
+func (b *historyBuilder) completeCase(id annextest.CaseExecutionID, caseFunc any, result any) *historyBuilder {
+	scheduled := b.add(enums.EVENT_TYPE_ACTIVITY_TASK_SCHEDULED, func(e *history.HistoryEvent) {
+		e.Attributes = &history.HistoryEvent_ActivityTaskScheduledEventAttributes{
+			ActivityTaskScheduledEventAttributes: &history.ActivityTaskScheduledEventAttributes{
+				ActivityId:                   id.ActivityID(),
+				ActivityType:                 &common.ActivityType{Name: name.FuncName(caseFunc)},
+				TaskQueue:                    &taskqueue.TaskQueue{Name: replayTaskQueue},
+				ScheduleToCloseTimeout:       durationpb.New(time.Minute),
+				StartToCloseTimeout:          durationpb.New(time.Minute),
+				WorkflowTaskCompletedEventId: b.workflowTaskCompleted,
+			},
+		}
+	})
+	started := b.add(enums.EVENT_TYPE_ACTIVITY_TASK_STARTED, func(e *history.HistoryEvent) {
+		e.Attributes = &history.HistoryEvent_ActivityTaskStartedEventAttributes{
+			ActivityTaskStartedEventAttributes: &history.ActivityTaskStartedEventAttributes{
+				ScheduledEventId: scheduled,
+				Attempt:          1,
+			},
+		}
+	})
+	b.add(enums.EVENT_TYPE_ACTIVITY_TASK_COMPLETED, func(e *history.HistoryEvent) {
+		e.Attributes = &history.HistoryEvent_ActivityTaskCompletedEventAttributes{
+			ActivityTaskCompletedEventAttributes: &history.ActivityTaskCompletedEventAttributes{
+				Result:           b.payloads(result),
+				ScheduledEventId: scheduled,
+				StartedEventId:   started,
+			},
+		}
+	})
+	b.workflowTask()
+	return b
+}
+
+func (b *historyBuilder) completed() *history.History {
+	b.add(enums.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED, func(e *history.HistoryEvent) {
+		e.Attributes = &history.HistoryEvent_WorkflowExecutionCompletedEventAttributes{
+			WorkflowExecutionCompletedEventAttributes: &history.WorkflowExecutionCompletedEventAttributes{
+				WorkflowTaskCompletedEventId: b.workflowTaskCompleted,
+			},
+		}
+	})
+	return &history.History{Events: b.events}
+}
+
+func (b *historyBuilder) continuedAsNew(workflowType string, args ...any) *history.History {
+	b.add(enums.EVENT_TYPE_WORKFLOW_EXECUTION_CONTINUED_AS_NEW, func(e *history.HistoryEvent) {
+		e.Attributes = &history.HistoryEvent_WorkflowExecutionContinuedAsNewEventAttributes{
+			WorkflowExecutionContinuedAsNewEventAttributes: &history.WorkflowExecutionContinuedAsNewEventAttributes{
+				NewExecutionRunId:            uuid.NewString(),
+				WorkflowType:                 &common.WorkflowType{Name: workflowType},
+				TaskQueue:                    &taskqueue.TaskQueue{Name: replayTaskQueue},
+				Input:                        b.payloads(args...),
+				WorkflowTaskCompletedEventId: b.workflowTaskCompleted,
+			},
+		}
+	})
+	return &history.History{Events: b.events}
+}
+
+type nopLogPublisher struct{}
+
+func (nopLogPublisher) PublishLog(
+	context.Context,
+	*connect.Request[testsv1.PublishLogRequest],
+) (*connect.Response[testsv1.PublishLogResponse], error) {
+	return connect.NewResponse(&testsv1.PublishLogResponse{LogId: uuid.NewString()}), nil
+}
+
+// replayTest replays hist with the test registered as a runner registers it.
+// Tests only publish errors, so that their info logs are not flushed with a
+// local activity.
+func replayTest(t *testing.T, rt *test.Runtime, tester tester, hist *history.History) error {
+	logger := log.NewNopLogger()
+	pub := temporal.NewBatchPublisher(nopLogPublisher{}, logger)
+	t.Cleanup(pub.Close)
+
+	replayer, err := worker.NewWorkflowReplayerWithOptions(worker.WorkflowReplayerOptions{
+		Interceptors: []interceptor.WorkerInterceptor{
+			test.NewWorkerInterceptor(rt),
+			temporal.NewWorkerLogInterceptor(logger, pub, temporal.LogLevels{}, rt.Redactor),
+		},
+		DisableRegistrationAliasing: true,
+	})
+	require.NoError(t, err)
+
+	publishLevel := slog.LevelError
+	wf := test.LogLevelTest(temporal.LogLevelOverrides{Publish: &publishLevel}, tester.workflow)
+	replayer.RegisterWorkflowWithOptions(wf, workflow.RegisterOptions{Name: testWorkflowName})
+
+	return replayer.ReplayWorkflowHistoryWithOptions(
+		tlog.NewStructuredLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		hist,
+		worker.ReplayWorkflowHistoryOptions{
+			OriginalExecution: workflow.Execution{
+				ID:    annextest.NewTestExecutionID().WorkflowID(),
+				RunID: uuid.NewString(),
+			},
+		},
+	)
+}
+
+func caseResult[R any](result R) test.CaseResponse[R] {
+	return test.CaseResponse[R]{Result: result, Finished: time.Now(), Duration: time.Second}
+}
+
+func TestReplay(t *testing.T) {
+	input, err := converter.GetDefaultDataConverter().ToPayload(replayParam{Name: "input"})
+	require.NoError(t, err)
+	inputPayload := &testsv1.Payload{Metadata: input.Metadata, Data: input.Data}
+
+	tests := []struct {
+		name    string
+		tester  tester
+		history func(t *testing.T) *history.History
+		wantErr string
+	}{
+		{
+			name: "simple test",
+			tester: &simpleTest{test: func(t TestT) {
+				RequireSuccess(t, StartCase(t, replayCase))
+				t.Logger().Info("first case passed")
+				res := RequireSuccessResult[string](t, StartCase(t, replayCase))
+				require.Equal(t, "ok", res)
+			}},
+			history: func(t *testing.T) *history.History {
+				return newHistoryBuilder(t, testWorkflowName, nil, nil).
+					completeCase(1, replayCase, caseResult[any](nil)).
+					completeCase(2, replayCase, caseResult("ok")).
+					completed()
+			},
+		},
+		{
+			name: "simple test with different case",
+			tester: &simpleTest{test: func(t TestT) {
+				RequireSuccess(t, StartCase(t, replayCase))
+			}},
+			history: func(t *testing.T) *history.History {
+				return newHistoryBuilder(t, testWorkflowName, nil, nil).
+					completeCase(1, "otherCase", caseResult[any](nil)).
+					completed()
+			},
+			wantErr: "TMPRL1100",
+		},
+		{
+			name: "param test",
+			tester: newParamTest(func(t TestT, p replayParam) {
+				require.Equal(t, "input", p.Name)
+				RequireSuccess(t, StartCase(t, replayCase, WithInput(p)))
+			}, replayParam{Name: "default"}),
+			history: func(t *testing.T) *history.History {
+				return newHistoryBuilder(t, testWorkflowName, inputPayload, nil).
+					completeCase(1, replayCase, caseResult[any](nil)).
+					completed()
+			},
+		},
+		{
+			name: "param test with default input",
+			tester: newParamTest(func(t TestT, p replayParam) {
+				require.Equal(t, "default", p.Name)
+				RequireSuccess(t, StartCase(t, replayCase, WithInput(p)))
+			}, replayParam{Name: "default"}),
+			history: func(t *testing.T) *history.History {
+				metadataOnly := &testsv1.Payload{Metadata: map[string][]byte{test.LogLevelMetadataKey: []byte("debug")}}
+				return newHistoryBuilder(t, testWorkflowName, metadataOnly, nil).
+					completeCase(1, replayCase, caseResult[any](nil)).
+					completed()
+			},
+		},
+		{
+			name: "table test",
+			tester: &tableTest[replayParam]{
+				rows: map[string]replayParam{"b": {Name: "b"}, "a": {Name: "a"}},
+				test: func(t TestT, p replayParam) {
+					RequireSuccess(t, StartCase(t, replayCase, WithInput(p)))
+				},
+			},
+			history: func(t *testing.T) *history.History {
+				return newHistoryBuilder(t, testWorkflowName, nil, nil).
+					completeCase(1, replayCase, caseResult[any](nil)).
+					completeCase(2, replayCase, caseResult[any](nil)).
+					completed()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := replayTest(t, newTestRuntime(), tt.tester, tt.history(t))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestReplay_Checkpoint(t *testing.T) {
+	// The test runs one case per run, checkpointing the number of cases run.
+	tester := &simpleTest{test: func(t TestT) {
+		var done int
+		RestoreCheckpoint(t, &done)
+		RequireSuccess(t, StartCase(t, replayCase))
+		if done == 0 {
+			ContinueAsNew(t, done+1)
+		}
+	}}
+
+	state, err := converter.GetDefaultDataConverter().ToPayload(1)
+	require.NoError(t, err)
+	checkpoint := &test.Checkpoint{LastCaseExecID: 1, State: state}
+
+	tests := []struct {
+		name    string
+		history *history.History
+		wantErr string
+	}{
+		{
+			name: "first run",
+			history: newHistoryBuilder(t, testWorkflowName, nil, nil).
+				completeCase(1, replayCase, caseResult[any](nil)).
+				continuedAsNew(testWorkflowName, nil, checkpoint),
+		},
+		{
+			name: "continued run",
+			history: newHistoryBuilder(t, testWorkflowName, nil, checkpoint).
+				completeCase(2, replayCase, caseResult[any](nil)).
+				completed(),
+		},
+		{
+			name: "continued run restarting case numbering",
+			history: newHistoryBuilder(t, testWorkflowName, nil, checkpoint).
+				completeCase(1, replayCase, caseResult[any](nil)).
+				completed(),
+			wantErr: "TMPRL1100",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := replayTest(t, newTestRuntime(), tester, tt.history)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestReplay_SealedSecrets(t *testing.T) {
+	rt := newTestRuntime()
+	rt.Secrets, _ = param.NewSecretCipher([]byte("0123456789abcdef0123456789abcdef"))
+
+	caseParam := replaySecretParam{User: "admin", Password: "hunter22"}
+	tester := &simpleTest{test: func(t TestT) {
+		RequireSuccess(t, StartCase(t, replayCase, WithInput(caseParam)))
+	}}
+
+	sealed, err := rt.Secrets.Seal(caseParam)
+	require.NoError(t, err)
+	sealedPayload, err := rt.Converter().ToPayload(sealed)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		history *history.History
+		wantErr string
+	}{
+		{
+			name: "sealed in side effect",
+			history: newHistoryBuilder(t, testWorkflowName, nil, nil).
+				sideEffect(replaySealedPayload{Payload: sealedPayload}).
+				completeCase(1, replayCase, caseResult[any](nil)).
+				completed(),
+		},
+		{
+			name: "history without side effect",
+			history: newHistoryBuilder(t, testWorkflowName, nil, nil).
+				completeCase(1, replayCase, caseResult[any](nil)).
+				completed(),
+			wantErr: "TMPRL1100",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := replayTest(t, rt, tester, tt.history)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestReplay_LogFlush(t *testing.T) {
+	// Error logs are published, so they are buffered and flushed with a local
+	// activity before the case starts and when the test completes.
+	tester := &simpleTest{test: func(t TestT) {
+		t.Logger().Error("before case")
+		t.Logger().Error("before case", "n", 2)
+		RequireSuccess(t, StartCase(t, replayCase))
+		t.Logger().Error("after case")
+	}}
+
+	tests := []struct {
+		name    string
+		history *history.History
+		wantErr string
+	}{
+		{
+			name: "flushed",
+			history: newHistoryBuilder(t, testWorkflowName, nil, nil).
+				flushLogs(2, false).
+				completeCase(1, replayCase, caseResult[any](nil)).
+				flushLogs(1, false).
+				completed(),
+		},
+		{
+			name: "failed flushes",
+			history: newHistoryBuilder(t, testWorkflowName, nil, nil).
+				flushLogs(0, true).
+				completeCase(1, replayCase, caseResult[any](nil)).
+				flushLogs(0, true).
+				completed(),
+		},
+		{
+			name: "history without flush",
+			history: newHistoryBuilder(t, testWorkflowName, nil, nil).
+				completeCase(1, replayCase, caseResult[any](nil)).
+				flushLogs(1, false).
+				completed(),
+			wantErr: "TMPRL1100",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := replayTest(t, newTestRuntime(), tester, tt.history)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -1,15 +1,17 @@
 package annex
 
 import (
-	"bytes"
 	"context"
 	"crypto/sha256"
-	"encoding/gob"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"reflect"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -24,8 +26,11 @@ import (
 	"go.temporal.io/sdk/interceptor"
 	"go.temporal.io/sdk/worker"
 	"go.temporal.io/sdk/workflow"
+	"google.golang.org/protobuf/proto"
 
+	"github.com/annexsh/annex-sdk-go/internal/param"
 	"github.com/annexsh/annex-sdk-go/internal/temporal"
+	"github.com/annexsh/annex-sdk-go/internal/test"
 )
 
 type TestSuiteRunnerConfig struct {
@@ -34,6 +39,69 @@ type TestSuiteRunnerConfig struct {
 	TestSuiteName string
 	TestSuiteDesc string     // optional
 	Logger        log.Logger // optional
+	// SecretKey is the 16, 24 or 32 byte AES key used to encrypt fields tagged
+	// `annex:"secret"` in case inputs, case results and test outputs. Every
+	// runner of the suite must use the same key. It is required to register
+	// input cases, or to return results and outputs, with secret fields.
+	SecretKey []byte // optional
+	// DataConverter encodes test params, case inputs and case results. Use
+	// NewDataConverter to add compression or encryption codecs. Defaults to
+	// converter.GetDefaultDataConverter(). Default and example inputs are
+	// always published as plain JSON so that they can be edited in Annex.
+	DataConverter converter.DataConverter // optional
+	// ArtifactSink stores artifacts attached with TestT.Attach and
+	// CaseT.Attach, such as NewLocalArtifactSink or NewBlobArtifactSink.
+	// Attaching an artifact fails if unset.
+	ArtifactSink ArtifactSink // optional
+	// TagsSearchAttribute is the name of a keyword list search attribute, which
+	// must already exist in the namespace, that test tags are stored in. Tags
+	// are only stored in the workflow memo if unset.
+	TagsSearchAttribute string // optional
+	// LogQueueSize is the maximum number of case logs waiting to be published
+	// to Annex. Logs are dropped while the queue is full. Defaults to 10000.
+	LogQueueSize int // optional
+	// LogBatchSize is the number of queued case logs that triggers a publish.
+	// Defaults to 100.
+	LogBatchSize int // optional
+	// LogFlushInterval is the maximum time a case log waits in the queue
+	// before it is published. Defaults to 1s.
+	LogFlushInterval time.Duration // optional
+	// LocalLogLevel is the minimum level of test and case logs written to
+	// Logger. Defaults to slog.LevelDebug. Use a *slog.LevelVar to change it
+	// while the runner is running.
+	LocalLogLevel slog.Leveler // optional
+	// PublishLogLevel is the minimum level of test and case logs published to
+	// Annex. Defaults to slog.LevelDebug. Use a *slog.LevelVar to change it
+	// while the runner is running. It can be overridden for a test with
+	// WithPublishLogLevel and for a single execution by setting the "log-level"
+	// metadata of the test input passed to ExecuteTest to a level name such as
+	// "debug". Input tests run with their default input when the input has
+	// metadata only. See the README for an example.
+	PublishLogLevel slog.Leveler // optional
+	// LogSpoolDir is a directory where test and case logs that can't be
+	// published while Annex is unreachable are stored. Spooled logs are
+	// published in order, with their original timestamps, once Annex is
+	// reachable again, including by the next runner started with the same
+	// directory. The directory is locked while the runner is running, so each
+	// runner needs its own. Logs that fail to publish are dropped if unset.
+	LogSpoolDir string // optional
+	// LogSpoolMaxSize is the maximum size in bytes of the log spool. Logs are
+	// dropped while the spool is full. Defaults to 64MiB.
+	LogSpoolMaxSize int64 // optional
+	// MetricsHandler receives the Temporal client metrics and the
+	// "annex_log_dropped" counter of test logs that were never published,
+	// tagged with the reason they were dropped.
+	MetricsHandler client.MetricsHandler // optional
+	// RedactKeys are log attribute keys, such as "x-session-id", whose values
+	// are masked in test and case logs, in addition to well-known keys such as
+	// "authorization", "cookie", "password" and "token". Keys are matched
+	// case-insensitively, including the keys of http.Header,
+	// map[string]string and map[string][]string values.
+	RedactKeys []string // optional
+	// RedactPatterns are masked wherever they match in test and case logs, in
+	// addition to bearer and basic credentials. Secret values can also be
+	// registered with RegisterSecret.
+	RedactPatterns []*regexp.Regexp // optional
 }
 
 type TestSuiteRunner struct {
@@ -44,6 +112,11 @@ type TestSuiteRunner struct {
 	client          client.Client
 	worker          worker.Worker
 	testClient      testsv1connect.TestServiceClient
+	taskQueue       string
+	runtime         *test.Runtime
+	tagsAttribute   string
+	logPublisher    *temporal.BatchPublisher
+	logSpool        *temporal.LogSpool
 	registeredTests []registeredTest
 }
 
@@ -55,12 +128,35 @@ func NewTestSuiteRunner(cfg TestSuiteRunnerConfig) (*TestSuiteRunner, error) {
 		logger = log.NewLogger()
 	}
 
+	var secrets *param.SecretCipher
+	if len(cfg.SecretKey) > 0 {
+		var err error
+		if secrets, err = param.NewSecretCipher(cfg.SecretKey); err != nil {
+			return nil, err
+		}
+	}
+
 	httpc := &http.Client{Timeout: time.Minute}
 
 	connectURL := "http://" + cfg.HostPort + "/connect"
 	testClient := testsv1connect.NewTestServiceClient(httpc, connectURL)
 
-	temporalClient, err := temporal.NewClient(ctx, logger, cfg.HostPort, cfg.Context)
+	dc := cfg.DataConverter
+	if dc == nil {
+		dc = converter.GetDefaultDataConverter()
+	}
+
+	redactor := temporal.NewRedactor(
+		slices.Concat(temporal.DefaultRedactKeys, cfg.RedactKeys),
+		slices.Concat(temporal.DefaultRedactPatterns, cfg.RedactPatterns),
+	)
+
+	metrics := cfg.MetricsHandler
+	if metrics == nil {
+		metrics = client.MetricsNopHandler
+	}
+
+	temporalClient, err := temporal.NewClient(ctx, logger, redactor, dc, metrics, cfg.HostPort, cfg.Context)
 	if err != nil {
 		return nil, err
 	}
@@ -81,39 +177,221 @@ func NewTestSuiteRunner(cfg TestSuiteRunnerConfig) (*TestSuiteRunner, error) {
 	taskQueue := getTaskQueue(cfg.Context, suiteRes.Msg.Id)
 	id := getRunnerIdentify(taskQueue)
 
+	runtime := &test.Runtime{
+		Context:       cfg.Context,
+		DataConverter: dc,
+		Secrets:       secrets,
+		Artifacts:     cfg.ArtifactSink,
+		Redactor:      redactor,
+	}
+
+	var logSpool *temporal.LogSpool
+	if cfg.LogSpoolDir != "" {
+		var spoolOpts []temporal.LogSpoolOption
+		if cfg.LogSpoolMaxSize > 0 {
+			spoolOpts = append(spoolOpts, temporal.WithLogSpoolMaxSize(cfg.LogSpoolMaxSize))
+		}
+		logSpool, err = temporal.NewLogSpool(cfg.LogSpoolDir, testClient, logger, metrics, spoolOpts...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	logPub := temporal.NewSpoolingPublisher(testClient, logSpool)
+
+	pubOpts := []temporal.BatchPublisherOption{temporal.WithLogMetricsHandler(metrics)}
+	if cfg.LogQueueSize > 0 {
+		pubOpts = append(pubOpts, temporal.WithLogQueueSize(cfg.LogQueueSize))
+	}
+	if cfg.LogBatchSize > 0 {
+		pubOpts = append(pubOpts, temporal.WithLogBatchSize(cfg.LogBatchSize))
+	}
+	if cfg.LogFlushInterval > 0 {
+		pubOpts = append(pubOpts, temporal.WithLogFlushInterval(cfg.LogFlushInterval))
+	}
+	logPublisher := temporal.NewBatchPublisher(logPub, logger, pubOpts...)
+
 	wrk := worker.New(temporalClient, taskQueue, worker.Options{
 		DisableRegistrationAliasing: true,
 		Interceptors: []interceptor.WorkerInterceptor{
-			temporal.NewWorkerLogInterceptor(logger, testClient),
+			test.NewWorkerInterceptor(runtime),
+			temporal.NewWorkerLogInterceptor(logger, logPublisher, temporal.LogLevels{
+				Local:   cfg.LocalLogLevel,
+				Publish: cfg.PublishLogLevel,
+			}, redactor),
 		},
 		Identity: id,
 	})
 
-	wrk.RegisterActivity(temporal.NewTestLogActivity(testClient))
+	wrk.RegisterActivity(temporal.NewTestLogActivity(logPub, cfg.PublishLogLevel, metrics))
+	wrk.RegisterActivity(test.NewArtifactActivity(cfg.ArtifactSink))
 
 	return &TestSuiteRunner{
-		ctx:        ctx,
-		id:         id,
-		context:    cfg.Context,
-		suiteID:    suiteRes.Msg.Id,
-		client:     temporalClient,
-		worker:     wrk,
-		testClient: testClient,
+		ctx:           ctx,
+		id:            id,
+		context:       cfg.Context,
+		suiteID:       suiteRes.Msg.Id,
+		client:        temporalClient,
+		worker:        wrk,
+		testClient:    testClient,
+		taskQueue:     taskQueue,
+		runtime:       runtime,
+		tagsAttribute: cfg.TagsSearchAttribute,
+		logPublisher:  logPublisher,
+		logSpool:      logSpool,
 	}, nil
 }
 
-func RegisterTest(runner *TestSuiteRunner, name string, test func(t TestT)) {
+type testOptions struct {
+	defaultInput    any
+	hasDefaultInput bool
+	examples        map[string]any
+	tags            []string
+	logLevels       temporal.LogLevelOverrides
+}
+
+// TestOption configures a registered test.
+type TestOption func(opts *testOptions)
+
+// InputTestOption configures a registered input test.
+type InputTestOption = TestOption
+
+// WithTags declares static tags for the test. Tags are stored in the memo of
+// every execution under "tags" and, if TestSuiteRunnerConfig.TagsSearchAttribute
+// is set, in that search attribute so that executions can be queried by tag.
+func WithTags(tags ...string) TestOption {
+	return func(opts *testOptions) {
+		opts.tags = append(opts.tags, tags...)
+	}
+}
+
+// WithLocalLogLevel overrides TestSuiteRunnerConfig.LocalLogLevel for the
+// test and the cases it starts.
+func WithLocalLogLevel(level slog.Level) TestOption {
+	return func(opts *testOptions) {
+		opts.logLevels.Local = &level
+	}
+}
+
+// WithPublishLogLevel overrides TestSuiteRunnerConfig.PublishLogLevel for the
+// test and the cases it starts.
+func WithPublishLogLevel(level slog.Level) TestOption {
+	return func(opts *testOptions) {
+		opts.logLevels.Publish = &level
+	}
+}
+
+// WithDefaultInput sets the input prefilled when the test is triggered. It
+// must be of the test's parameter type. Defaults to the zero value. It is only
+// valid for input tests.
+func WithDefaultInput(input any) InputTestOption {
+	return func(opts *testOptions) {
+		opts.defaultInput = input
+		opts.hasDefaultInput = true
+	}
+}
+
+// WithExampleInput adds a named preset input that can be picked when the test
+// is triggered. It must be of the test's parameter type. It is only valid for
+// input tests.
+func WithExampleInput(name string, input any) InputTestOption {
+	return func(opts *testOptions) {
+		if opts.examples == nil {
+			opts.examples = map[string]any{}
+		}
+		opts.examples[name] = input
+	}
+}
+
+func getTestOptions(opts []TestOption) testOptions {
+	var options testOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// mustNotHaveInput panics if input options are set for a test that does not
+// take an input.
+func mustNotHaveInput(testName string, options testOptions) {
+	if options.hasDefaultInput || len(options.examples) > 0 {
+		panic(fmt.Sprintf("test %s does not have a parameter: default and example inputs are not supported", testName))
+	}
+}
+
+func RegisterTest(runner *TestSuiteRunner, name string, test func(t TestT), opts ...TestOption) {
+	options := getTestOptions(opts)
+	mustNotHaveInput(name, options)
+
 	runner.registeredTests = append(runner.registeredTests, registeredTest{
-		name: name,
-		test: &simpleTest{test: test},
+		name:      name,
+		test:      &simpleTest{test: test},
+		tags:      options.tags,
+		logLevels: options.logLevels,
 	})
 }
 
-func RegisterInputTest[P any](runner *TestSuiteRunner, name string, test func(t TestT, param P)) {
+func RegisterInputTest[P any](runner *TestSuiteRunner, name string, test func(t TestT, param P), opts ...InputTestOption) {
+	options := getTestOptions(opts)
+	hasSecretParams[P]("test " + name)
+
+	defaultParam := param.Zero(reflect.TypeFor[P]())
+	if options.hasDefaultInput {
+		mustBeParam[P](name, "default input", options.defaultInput)
+		defaultParam = options.defaultInput
+	}
+	for exampleName, example := range options.examples {
+		mustBeParam[P](name, "example input "+exampleName, example)
+	}
+
 	runner.registeredTests = append(runner.registeredTests, registeredTest{
 		name:         name,
-		test:         &paramTest[P]{test: test},
-		defaultParam: *new(P),
+		test:         newParamTest(test, defaultParam.(P)),
+		defaultParam: defaultParam,
+		examples:     options.examples,
+		tags:         options.tags,
+		logLevels:    options.logLevels,
+	})
+}
+
+// hasSecretParams reports whether P has secret fields. It panics if the annex
+// tags of P are invalid, since its secret fields would otherwise be missed.
+func hasSecretParams[P any](desc string) bool {
+	has, err := param.HasSecrets(reflect.TypeFor[P]())
+	if err != nil {
+		panic(fmt.Sprintf("%s has an invalid param: %v", desc, err))
+	}
+	return has
+}
+
+func mustBeParam[P any](testName string, desc string, input any) {
+	if _, ok := input.(P); !ok {
+		panic(fmt.Sprintf("test %s %s must be of type %s: got %T", testName, desc, reflect.TypeFor[P](), input))
+	}
+}
+
+// RegisterTableTest registers a test with a named table of inputs. Each row is
+// registered as its own input test named "<name>/<row>" with the row as its
+// default input, and name runs every row in turn, reporting which rows failed.
+// Tags apply to the table test and every row.
+func RegisterTableTest[P any](runner *TestSuiteRunner, name string, rows map[string]P, test func(t TestT, param P), opts ...TestOption) {
+	options := getTestOptions(opts)
+	hasSecretParams[P]("test " + name)
+	mustNotHaveInput(name, options)
+
+	for _, rowName := range sortedRowNames(rows) {
+		runner.registeredTests = append(runner.registeredTests, registeredTest{
+			name:         tableRowTestName(name, rowName),
+			test:         newParamTest(test, rows[rowName]),
+			defaultParam: rows[rowName],
+			tags:         options.tags,
+			logLevels:    options.logLevels,
+		})
+	}
+	runner.registeredTests = append(runner.registeredTests, registeredTest{
+		name:      name,
+		test:      &tableTest[P]{rows: rows, test: test},
+		tags:      options.tags,
+		logLevels: options.logLevels,
 	})
 }
 
@@ -126,6 +404,9 @@ func RegisterCase(runner *TestSuiteRunner, caseFn func(t CaseT)) {
 
 func RegisterInputCase[P any](runner *TestSuiteRunner, caseFn func(t CaseT, param P)) {
 	c := paramCase[P]{caseFn: caseFn}
+	if hasSecretParams[P]("case "+c.name()) && runner.runtime.Secrets == nil {
+		panic(fmt.Sprintf("case %s has secret param fields: TestSuiteRunnerConfig.SecretKey must be set", c.name()))
+	}
 	runner.worker.RegisterActivityWithOptions(c.activity, activity.RegisterOptions{
 		Name: c.name(),
 	})
@@ -135,7 +416,11 @@ func (w *TestSuiteRunner) Run() error {
 	var defs []*testsv1.TestDefinition
 
 	for _, reg := range w.registeredTests {
-		w.worker.RegisterWorkflowWithOptions(reg.test.workflow, workflow.RegisterOptions{
+		wf := test.LogLevelTest(reg.logLevels, reg.test.workflow)
+		if len(reg.tags) > 0 {
+			wf = test.TaggedTest(reg.tags, w.tagsAttribute, wf)
+		}
+		w.worker.RegisterWorkflowWithOptions(wf, workflow.RegisterOptions{
 			Name: reg.name,
 		})
 
@@ -144,15 +429,12 @@ func (w *TestSuiteRunner) Run() error {
 			DefaultInput: nil,
 		}
 
-		if reg.defaultParam != nil {
-			p, err := converter.NewJSONPayloadConverter().ToPayload(reg.defaultParam)
+		if hasParam, paramType := reg.test.paramType(); hasParam {
+			payload, err := getDefaultInputPayload(reg, paramType)
 			if err != nil {
-				return err
-			}
-			def.DefaultInput = &testsv1.Payload{
-				Data:     p.Data,
-				Metadata: p.Metadata,
+				return fmt.Errorf("failed to marshal test %s default input: %w", reg.name, err)
 			}
+			def.DefaultInput = payload
 		}
 
 		defs = append(defs, def)
@@ -181,11 +463,18 @@ func (w *TestSuiteRunner) Run() error {
 		return err
 	}
 
+	// Publish the remaining case logs once the worker has stopped, spooling
+	// those that can't be published.
+	if w.logSpool != nil {
+		defer w.logSpool.Close()
+	}
+	defer w.logPublisher.Close()
+
 	return w.worker.Run(worker.InterruptCh())
 }
 
 type tester interface {
-	workflow(ctx workflow.Context, payload *testsv1.Payload) error
+	workflow(ctx workflow.Context, payload *testsv1.Payload, checkpoint *test.Checkpoint) (*test.Output, error)
 	paramType() (bool, reflect.Type)
 }
 
@@ -193,6 +482,77 @@ type registeredTest struct {
 	name         string
 	test         tester
 	defaultParam any
+	examples     map[string]any
+	tags         []string
+	logLevels    temporal.LogLevelOverrides
+}
+
+// TestDefinition has no fields for example inputs or the parameter schema, so
+// they are published in the default input payload metadata under the keys
+// below. This is the contract Annex clients read until the fields are added to
+// the annex.tests.v1 protos, at which point both will be published for a
+// release.
+//
+// TODO: publish examples and schema in TestDefinition fields once they exist.
+const (
+	// examplesMetadataKey is the default input payload metadata key holding the
+	// named example inputs as a JSON object of name to input.
+	examplesMetadataKey = "examples"
+	// schemaMetadataKey is the default input payload metadata key holding the
+	// JSON Schema of the test parameter.
+	schemaMetadataKey = "schema"
+)
+
+func getDefaultInputPayload(reg registeredTest, paramType reflect.Type) (*testsv1.Payload, error) {
+	// Inputs are published as JSON so that they can be edited in Annex.
+	pc := converter.NewCompositeDataConverter(
+		converter.NewNilPayloadConverter(),
+		converter.NewProtoJSONPayloadConverter(),
+		converter.NewJSONPayloadConverter(),
+	)
+
+	// Secret values must never be published, so they are cleared from the
+	// default and example inputs.
+	defaultParam, err := param.ClearSecrets(reg.defaultParam)
+	if err != nil {
+		return nil, err
+	}
+	p, err := pc.ToPayload(defaultParam)
+	if err != nil {
+		return nil, err
+	}
+
+	schema, err := param.MarshalSchema(paramType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate parameter schema: %w", err)
+	}
+	p.Metadata[schemaMetadataKey] = schema
+
+	if len(reg.examples) > 0 {
+		examples := make(map[string]json.RawMessage, len(reg.examples))
+		for name, example := range reg.examples {
+			example, err := param.ClearSecrets(example)
+			if err != nil {
+				return nil, fmt.Errorf("example input %s: %w", name, err)
+			}
+			ep, err := pc.ToPayload(example)
+			if err != nil {
+				return nil, fmt.Errorf("example input %s: %w", name, err)
+			}
+			examples[name] = ep.Data
+		}
+		// Map keys are sorted when marshaled so the suite version is stable.
+		data, err := json.Marshal(examples)
+		if err != nil {
+			return nil, err
+		}
+		p.Metadata[examplesMetadataKey] = data
+	}
+
+	return &testsv1.Payload{
+		Data:     p.Data,
+		Metadata: p.Metadata,
+	}, nil
 }
 
 func getTaskQueue(context string, suiteID string) string {
@@ -212,12 +572,16 @@ func getHostName() string {
 }
 
 func getTestSuiteVersion(defs []*testsv1.TestDefinition) (string, error) {
-	var buffer bytes.Buffer
-	encoder := gob.NewEncoder(&buffer)
-	if err := encoder.Encode(defs); err != nil {
-		return "", err
+	// Deterministic marshaling orders payload metadata map entries so that the
+	// version is stable across runs.
+	marshaler := proto.MarshalOptions{Deterministic: true}
+	hash := sha256.New()
+	for _, def := range defs {
+		b, err := marshaler.Marshal(def)
+		if err != nil {
+			return "", err
+		}
+		hash.Write(b)
 	}
-
-	hash := sha256.Sum256(buffer.Bytes())
-	return hex.EncodeToString(hash[:]), nil
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
@@ -0,0 +1,56 @@
+package annex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/annexsh/annex-sdk-go/internal/test"
+)
+
+type invalidSecretParam struct {
+	Pin int `json:"pin" annex:"secret"`
+}
+
+func TestRegister_InvalidSecretTags(t *testing.T) {
+	tests := []struct {
+		name      string
+		register  func(runner *TestSuiteRunner)
+		wantPanic string
+	}{
+		{
+			name: "input case",
+			register: func(runner *TestSuiteRunner) {
+				RegisterInputCase(runner, func(CaseT, invalidSecretParam) {})
+			},
+			wantPanic: "has an invalid param",
+		},
+		{
+			name: "input test",
+			register: func(runner *TestSuiteRunner) {
+				RegisterInputTest(runner, "input", func(TestT, invalidSecretParam) {})
+			},
+			wantPanic: "test input has an invalid param",
+		},
+		{
+			name: "table test",
+			register: func(runner *TestSuiteRunner) {
+				RegisterTableTest(runner, "table", map[string]invalidSecretParam{"a": {}}, func(TestT, invalidSecretParam) {})
+			},
+			wantPanic: "test table has an invalid param",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &TestSuiteRunner{runtime: &test.Runtime{}}
+			defer func() {
+				r := recover()
+				assert.Contains(t, r, tt.wantPanic)
+				assert.Contains(t, r, "secret field Pin must be a string")
+				assert.Empty(t, runner.registeredTests)
+			}()
+			tt.register(runner)
+		})
+	}
+}
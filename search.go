@@ -0,0 +1,137 @@
+package annex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/annexsh/annex/test"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/annexsh/annex-sdk-go/internal/param"
+	sdktest "github.com/annexsh/annex-sdk-go/internal/test"
+)
+
+// reservedMemoKeys are the memo keys managed by the SDK.
+var reservedMemoKeys = []string{sdktest.AnnotationsMemoKey, sdktest.OutputMemoKey, sdktest.TagsMemoKey}
+
+// UpsertSearchAttributes sets typed search attributes on the test execution so
+// that it can be found with a visibility query, e.g.
+//
+//	annex.UpsertSearchAttributes(t,
+//		temporal.NewSearchAttributeKeyKeyword("Environment").ValueSet("staging"),
+//		temporal.NewSearchAttributeKeyInt64("Build").ValueSet(1234),
+//	)
+//
+// Custom search attributes must already exist in the namespace.
+func UpsertSearchAttributes(t TestT, attributes ...temporal.SearchAttributeUpdate) {
+	err := workflow.UpsertTypedSearchAttributes(getWorkflowT(t).WorkflowContext(), attributes...)
+	require.NoError(t, err, "failed to upsert search attributes")
+}
+
+// UpsertMemo sets memo fields on the test execution. Secret param fields are
+// redacted. The "annotations", "output" and "tags" fields are managed by the
+// SDK and cannot be set.
+func UpsertMemo(t TestT, memo map[string]any) {
+	redacted := make(map[string]any, len(memo))
+	for key, value := range memo {
+		require.False(t, slices.Contains(reservedMemoKeys, key), "memo field %s is reserved", key)
+		redacted[key] = param.Redact(value)
+	}
+	err := workflow.UpsertMemo(getWorkflowT(t).WorkflowContext(), redacted)
+	require.NoError(t, err, "failed to upsert memo")
+}
+
+// TestExecutionInfo describes a test execution found by ListTestExecutions.
+type TestExecutionInfo struct {
+	ID        string
+	Test      string
+	Status    string
+	StartTime time.Time
+	// CloseTime is zero if the test execution is still running.
+	CloseTime   time.Time
+	Tags        []string
+	Annotations map[string]string
+	// Output is the test output as a raw JSON value with its secret fields
+	// redacted. It is empty if the test did not set an output.
+	Output string
+	// Memo holds the memo fields set with UpsertMemo as raw JSON values.
+	Memo map[string]string
+}
+
+// ListTestExecutions lists the executions of this test suite that match a
+// Temporal visibility query, e.g. `Environment = 'staging' AND Build = 1234`.
+// An empty query lists every execution.
+func (w *TestSuiteRunner) ListTestExecutions(ctx context.Context, query string) ([]*TestExecutionInfo, error) {
+	q := fmt.Sprintf("TaskQueue = '%s'", w.taskQueue)
+	if query != "" {
+		q += " AND (" + query + ")"
+	}
+
+	// Memo fields are encoded by the worker with the runner data converter.
+	dc := w.runtime.Converter()
+
+	var infos []*TestExecutionInfo
+	var pageToken []byte
+	for {
+		res, err := w.client.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+			Query:         q,
+			NextPageToken: pageToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, exec := range res.Executions {
+			testExecID, err := test.ParseTestWorkflowID(exec.GetExecution().GetWorkflowId())
+			if err != nil {
+				// Not a test workflow
+				continue
+			}
+
+			info := &TestExecutionInfo{
+				ID:          testExecID.String(),
+				Test:        exec.GetType().GetName(),
+				Status:      exec.GetStatus().String(),
+				StartTime:   exec.GetStartTime().AsTime(),
+				Annotations: map[string]string{},
+				Memo:        map[string]string{},
+			}
+			if exec.CloseTime != nil {
+				info.CloseTime = exec.GetCloseTime().AsTime()
+			}
+
+			for key, payload := range exec.GetMemo().GetFields() {
+				switch key {
+				case sdktest.TagsMemoKey:
+					err = dc.FromPayload(payload, &info.Tags)
+				case sdktest.AnnotationsMemoKey:
+					err = dc.FromPayload(payload, &info.Annotations)
+				case sdktest.OutputMemoKey:
+					var raw json.RawMessage
+					err = dc.FromPayload(payload, &raw)
+					info.Output = string(raw)
+				default:
+					var raw json.RawMessage
+					err = dc.FromPayload(payload, &raw)
+					info.Memo[key] = string(raw)
+				}
+				if err != nil {
+					return nil, fmt.Errorf("failed to decode test execution %s memo %s: %w", info.ID, key, err)
+				}
+			}
+
+			infos = append(infos, info)
+		}
+
+		pageToken = res.NextPageToken
+		if len(pageToken) == 0 {
+			return infos, nil
+		}
+	}
+}
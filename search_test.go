@@ -0,0 +1,210 @@
+package annex
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	annextest "github.com/annexsh/annex/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/workflow"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/annexsh/annex-sdk-go/internal/param"
+	"github.com/annexsh/annex-sdk-go/internal/test"
+)
+
+type searchMemoParam struct {
+	User     string `json:"user"`
+	Password string `json:"password" annex:"secret"`
+}
+
+// memoFromTest returns the memo of the test workflow executing t decoded as raw
+// JSON values.
+func memoFromTest(t TestT) map[string]string {
+	ctx := getWorkflowT(t).WorkflowContext()
+	memo := map[string]string{}
+	for key, payload := range workflow.GetInfo(ctx).Memo.GetFields() {
+		var raw json.RawMessage
+		require.NoError(t, converter.GetDefaultDataConverter().FromPayload(payload, &raw))
+		memo[key] = string(raw)
+	}
+	return memo
+}
+
+func TestUpsertMemo(t *testing.T) {
+	tests := []struct {
+		name     string
+		memo     map[string]any
+		wantMemo map[string]string
+		wantErr  string
+	}{
+		{
+			name: "redacts secrets",
+			memo: map[string]any{
+				"login": searchMemoParam{User: "admin", Password: "hunter22"},
+				"build": 1234,
+			},
+			wantMemo: map[string]string{
+				"login": `{"user":"admin","password":"` + param.RedactedValue + `"}`,
+				"build": `1234`,
+			},
+		},
+		{
+			name:    "annotations reserved",
+			memo:    map[string]any{test.AnnotationsMemoKey: map[string]string{}},
+			wantErr: "memo field annotations is reserved",
+		},
+		{
+			name:    "output reserved",
+			memo:    map[string]any{test.OutputMemoKey: "out"},
+			wantErr: "memo field output is reserved",
+		},
+		{
+			name:    "tags reserved",
+			memo:    map[string]any{test.TagsMemoKey: []string{"smoke"}},
+			wantErr: "memo field tags is reserved",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs []string
+			var gotMemo map[string]string
+			err := executeTestBody(t, func(t TestT) {
+				UpsertMemo(&errorRecordingT{TestT: t, WorkflowT: getWorkflowT(t), errs: &errs}, tt.memo)
+				gotMemo = memoFromTest(t)
+			})
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				require.Len(t, errs, 1)
+				assert.Contains(t, errs[0], tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, gotMemo, len(tt.wantMemo))
+			for key, want := range tt.wantMemo {
+				assert.JSONEq(t, want, gotMemo[key], key)
+			}
+		})
+	}
+}
+
+func TestTaggedTest(t *testing.T) {
+	var gotMemo map[string]string
+	tester := &simpleTest{test: func(t TestT) {
+		gotMemo = memoFromTest(t)
+	}}
+
+	env := newTestWorkflowEnv(t, newTestRuntime())
+	env.RegisterWorkflowWithOptions(test.TaggedTest([]string{"smoke", "api", "smoke"}, "", tester.workflow), workflow.RegisterOptions{
+		Name: testWorkflowName,
+	})
+	env.ExecuteWorkflow(testWorkflowName, nil, nil)
+	require.NoError(t, env.GetWorkflowError())
+	assert.JSONEq(t, `["api","smoke"]`, gotMemo[test.TagsMemoKey])
+}
+
+// listClient is a Temporal client that lists test executions one page at a
+// time.
+type listClient struct {
+	client.Client
+	pages    []*workflowservice.ListWorkflowExecutionsResponse
+	requests []*workflowservice.ListWorkflowExecutionsRequest
+}
+
+func (c *listClient) ListWorkflow(
+	_ context.Context,
+	req *workflowservice.ListWorkflowExecutionsRequest,
+) (*workflowservice.ListWorkflowExecutionsResponse, error) {
+	c.requests = append(c.requests, req)
+	page := c.pages[0]
+	c.pages = c.pages[1:]
+	return page, nil
+}
+
+func TestListTestExecutions(t *testing.T) {
+	rt := newTestRuntime()
+	memo := func(fields map[string]any) *common.Memo {
+		m := &common.Memo{Fields: map[string]*common.Payload{}}
+		for key, value := range fields {
+			payload, err := rt.Converter().ToPayload(value)
+			require.NoError(t, err)
+			m.Fields[key] = payload
+		}
+		return m
+	}
+
+	first := annextest.NewTestExecutionID()
+	second := annextest.NewTestExecutionID()
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	c := &listClient{pages: []*workflowservice.ListWorkflowExecutionsResponse{
+		{
+			Executions: []*workflowpb.WorkflowExecutionInfo{
+				{
+					Execution: &common.WorkflowExecution{WorkflowId: first.WorkflowID()},
+					Type:      &common.WorkflowType{Name: "checkout"},
+					Status:    enums.WORKFLOW_EXECUTION_STATUS_COMPLETED,
+					StartTime: timestamppb.New(start),
+					CloseTime: timestamppb.New(start.Add(time.Minute)),
+					Memo: memo(map[string]any{
+						test.TagsMemoKey:        []string{"smoke"},
+						test.AnnotationsMemoKey: map[string]string{"order": "o-1"},
+						test.OutputMemoKey:      param.Redact(searchMemoParam{User: "admin", Password: "hunter22"}),
+						"login":                 param.Redact(searchMemoParam{User: "admin", Password: "hunter22"}),
+					}),
+				},
+				{
+					Execution: &common.WorkflowExecution{WorkflowId: "not-a-test"},
+				},
+			},
+			NextPageToken: []byte("next"),
+		},
+		{
+			Executions: []*workflowpb.WorkflowExecutionInfo{
+				{
+					Execution: &common.WorkflowExecution{WorkflowId: second.WorkflowID()},
+					Type:      &common.WorkflowType{Name: "checkout"},
+					Status:    enums.WORKFLOW_EXECUTION_STATUS_RUNNING,
+					StartTime: timestamppb.New(start),
+				},
+			},
+		},
+	}}
+	runner := &TestSuiteRunner{client: c, runtime: rt, taskQueue: "suite"}
+
+	infos, err := runner.ListTestExecutions(context.Background(), "Build = 1234")
+	require.NoError(t, err)
+
+	require.Len(t, c.requests, 2)
+	assert.Equal(t, "TaskQueue = 'suite' AND (Build = 1234)", c.requests[0].Query)
+	assert.Equal(t, []byte("next"), c.requests[1].NextPageToken)
+
+	require.Len(t, infos, 2)
+	redactedLogin := `{"user":"admin","password":"` + param.RedactedValue + `"}`
+	got := infos[0]
+	assert.Equal(t, first.String(), got.ID)
+	assert.Equal(t, "checkout", got.Test)
+	assert.Equal(t, "Completed", got.Status)
+	assert.Equal(t, start, got.StartTime)
+	assert.Equal(t, start.Add(time.Minute), got.CloseTime)
+	assert.Equal(t, []string{"smoke"}, got.Tags)
+	assert.Equal(t, map[string]string{"order": "o-1"}, got.Annotations)
+	assert.JSONEq(t, redactedLogin, got.Output)
+	require.Contains(t, got.Memo, "login")
+	assert.JSONEq(t, redactedLogin, got.Memo["login"])
+
+	assert.Equal(t, second.String(), infos[1].ID)
+	assert.True(t, infos[1].CloseTime.IsZero())
+	assert.Empty(t, infos[1].Memo)
+	assert.Empty(t, infos[1].Annotations)
+}
@@ -6,7 +6,6 @@ import (
 	"strings"
 	"time"
 
-	"go.temporal.io/sdk/converter"
 	temporalsdk "go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 
@@ -65,7 +64,9 @@ func StartCase(t TestT, caseFunc any, opts ...StartCaseOption) *test.Pending {
 	if options.input == nil {
 		workflowFuture = workflow.ExecuteActivity(ctx, activityName)
 	} else {
-		payload, err := converter.GetDefaultDataConverter().ToPayload(options.input)
+		rt := test.RuntimeFromWorkflowContext(ctx)
+
+		payload, err := rt.EncodeSealed(ctx, options.input)
 		if err != nil {
 			return test.NewPendingError(execID, activityName, fmt.Errorf("failed to marshal case param: %w", err))
 		}
@@ -73,5 +74,7 @@ func StartCase(t TestT, caseFunc any, opts ...StartCaseOption) *test.Pending {
 		workflowFuture = workflow.ExecuteActivity(ctx, activityName, payload)
 	}
 
-	return test.NewPending(execID, caseName, workflowFuture)
+	pending := test.NewPending(execID, caseName, workflowFuture)
+	wt.TrackPending(pending)
+	return pending
 }
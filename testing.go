@@ -2,9 +2,19 @@ package annex
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
 
 	"github.com/stretchr/testify/require"
+	"go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/workflow"
 
+	"github.com/annexsh/annex-sdk-go/internal/param"
 	"github.com/annexsh/annex-sdk-go/internal/test"
 	"github.com/annexsh/annex-sdk-go/internal/testing"
 )
@@ -12,12 +22,32 @@ import (
 type TestT interface {
 	require.TestingT
 	Logger() testing.Logger
+	// Slog returns a slog logger that publishes to the test logs.
+	Slog() *slog.Logger
+	// Attach stores an artifact, such as a report or screenshot, with the
+	// configured artifact sink and records its reference in the test logs.
+	Attach(name string, contentType string, r io.Reader) error
 }
 
 type CaseT interface {
 	require.TestingT
 	Context() context.Context
 	Logger() testing.Logger
+	// Slog returns a slog logger that publishes to the case logs.
+	Slog() *slog.Logger
+	// Stdout and Stderr return writers that publish each line written to them
+	// as a case log. The process stdout and stderr are never captured since
+	// they are shared by concurrent cases, so inject these writers into
+	// clients that write diagnostics instead.
+	Stdout() io.Writer
+	Stderr() io.Writer
+	// StdLogger returns a standard library logger that publishes to the case
+	// logs.
+	StdLogger() *log.Logger
+	// Attach stores an artifact, such as a screenshot, HAR file or response
+	// body, with the configured artifact sink and records its reference in the
+	// case logs and response.
+	Attach(name string, contentType string, r io.Reader) error
 }
 
 func SetResult(t CaseT, result any) {
@@ -43,11 +73,109 @@ func RequireSuccess(t TestT, pending *test.Pending) {
 }
 
 func RequireSuccessResult[R any](t TestT, pending *test.Pending) R {
-	var res test.CaseResponse[R]
 	err := test.GetPendingError(pending)
 	require.NoError(t, err)
 	ctx := getWorkflowT(t).WorkflowContext()
+	rt := test.RuntimeFromWorkflowContext(ctx)
+
+	if param.IsProtoMessage(reflect.TypeFor[R]()) {
+		var res test.CaseResponse[*common.Payload]
+		err = test.GetPendingFuture(pending).Get(ctx, &res)
+		require.NoError(t, err)
+		var result R
+		if res.Result != nil {
+			err = rt.Converter().FromPayload(res.Result, &result)
+			require.NoError(t, err)
+		}
+		return result
+	}
+
+	var res test.CaseResponse[R]
 	err = test.GetPendingFuture(pending).Get(ctx, &res)
 	require.NoError(t, err)
+	err = rt.OpenSecrets(&res.Result)
+	require.NoError(t, err)
 	return res.Result
 }
+
+// ContinueAsNewSuggested reports whether the test workflow history has grown
+// large enough that the test should checkpoint and continue as new.
+func ContinueAsNewSuggested(t TestT) bool {
+	return workflow.GetInfo(getWorkflowT(t).WorkflowContext()).GetContinueAsNewSuggested()
+}
+
+// ContinueAsNew checkpoints state and continues the test as a new workflow run
+// with a fresh history. The test execution, case numbering and log stream are
+// unchanged. The test body is restarted from the beginning, so it should call
+// RestoreCheckpoint to resume from state. In a table test, the next run
+// resumes the table at the row that called ContinueAsNew, and only that row
+// restores state. All started cases must be complete before calling
+// ContinueAsNew. It never returns.
+func ContinueAsNew(t TestT, state any) {
+	wt := getWorkflowT(t)
+	// Cases still running would be abandoned by the new run and their results
+	// lost.
+	if unresolved := wt.UnresolvedPendings(); len(unresolved) > 0 {
+		names := make([]string, len(unresolved))
+		for i, pending := range unresolved {
+			names[i] = fmt.Sprintf("%s (case %s)", test.GetPendingName(pending), test.GetPendingID(pending))
+		}
+		require.Failf(t, "cannot continue as new with unresolved cases",
+			"%d started cases have not finished: %s", len(unresolved), strings.Join(names, ", "))
+	}
+	checkpoint := test.Checkpoint{
+		LastCaseExecID: wt.CurrentCaseExecutionID(),
+	}
+	if state != nil {
+		dc := test.RuntimeFromWorkflowContext(wt.WorkflowContext()).Converter()
+		payload, err := dc.ToPayload(state)
+		if err != nil {
+			panic(fmt.Sprintf("failed to marshal checkpoint state: %v", err))
+		}
+		checkpoint.State = payload
+	}
+	test.ContinueAsNew(checkpoint)
+}
+
+// RestoreCheckpoint decodes the state passed to ContinueAsNew by the previous
+// run into statePtr. It returns false if the test has not been continued as
+// new or no state was checkpointed.
+func RestoreCheckpoint(t TestT, statePtr any) bool {
+	wt := getWorkflowT(t)
+	state := wt.CheckpointState()
+	if state == nil {
+		return false
+	}
+	dc := test.RuntimeFromWorkflowContext(wt.WorkflowContext()).Converter()
+	err := dc.FromPayload(state, statePtr)
+	require.NoError(t, err, "failed to unmarshal checkpoint state")
+	return true
+}
+
+var slogDefaultMu sync.Mutex
+
+// WithSlogDefault installs the case's slog logger as the slog default logger
+// while fn runs, so that libraries logging through the slog package functions
+// publish to the case logs. The default logger is process-wide, so calls are
+// serialized by a global lock: a case calling WithSlogDefault blocks until fn
+// of any other case has returned, even if the runner executes cases
+// concurrently. Keep fn short, and prefer passing CaseT.Slog to libraries that
+// accept a logger. Logs from other goroutines are routed to the case while fn
+// runs.
+func WithSlogDefault(t CaseT, fn func()) {
+	slogDefaultMu.Lock()
+	defer slogDefaultMu.Unlock()
+
+	// slog.SetDefault redirects the standard log package to the new handler,
+	// which restoring the previous default does not undo.
+	prev := slog.Default()
+	prevWriter, prevFlags := log.Writer(), log.Flags()
+	slog.SetDefault(t.Slog())
+	defer func() {
+		slog.SetDefault(prev)
+		log.SetOutput(prevWriter)
+		log.SetFlags(prevFlags)
+	}()
+
+	fn()
+}
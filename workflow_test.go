@@ -0,0 +1,463 @@
+package annex
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/annexsh/annex-proto/go/gen/annex/tests/v1"
+	annextest "github.com/annexsh/annex/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/annexsh/annex-sdk-go/internal/param"
+	"github.com/annexsh/annex-sdk-go/internal/temporal"
+	"github.com/annexsh/annex-sdk-go/internal/test"
+	sdktesting "github.com/annexsh/annex-sdk-go/internal/testing"
+)
+
+const testWorkflowName = "test"
+
+type workflowTestParam struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func newTestWorkflowEnv(t *testing.T, rt *test.Runtime) *testsuite.TestWorkflowEnvironment {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(worker.Options{
+		Interceptors: []interceptor.WorkerInterceptor{test.NewWorkerInterceptor(rt)},
+	})
+	env.SetStartWorkflowOptions(client.StartWorkflowOptions{
+		ID: annextest.NewTestExecutionID().WorkflowID(),
+	})
+	t.Cleanup(func() { env.AssertExpectations(t) })
+	return env
+}
+
+func newTestRuntime() *test.Runtime {
+	return &test.Runtime{
+		Context:       "default",
+		DataConverter: converter.GetDefaultDataConverter(),
+		Redactor:      temporal.NewRedactor(nil, nil),
+	}
+}
+
+func TestParamTest_Input(t *testing.T) {
+	defaultParam := workflowTestParam{Name: "default", Count: 1}
+	input, err := converter.GetDefaultDataConverter().ToPayload(workflowTestParam{Name: "input", Count: 2})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		payload   *testsv1.Payload
+		wantParam workflowTestParam
+		wantLevel *slog.Level
+		wantErr   string
+	}{
+		{
+			name:      "no payload uses default",
+			payload:   nil,
+			wantParam: defaultParam,
+		},
+		{
+			name:      "input",
+			payload:   &testsv1.Payload{Metadata: input.Metadata, Data: input.Data},
+			wantParam: workflowTestParam{Name: "input", Count: 2},
+		},
+		{
+			name: "metadata only uses default",
+			payload: &testsv1.Payload{Metadata: map[string][]byte{
+				test.LogLevelMetadataKey: []byte("debug"),
+			}},
+			wantParam: defaultParam,
+			wantLevel: levelPtr(slog.LevelDebug),
+		},
+		{
+			name: "input with log level",
+			payload: &testsv1.Payload{
+				Metadata: map[string][]byte{
+					converter.MetadataEncoding: input.Metadata[converter.MetadataEncoding],
+					test.LogLevelMetadataKey:   []byte("warn"),
+				},
+				Data: input.Data,
+			},
+			wantParam: workflowTestParam{Name: "input", Count: 2},
+			wantLevel: levelPtr(slog.LevelWarn),
+		},
+		{
+			name: "invalid log level",
+			payload: &testsv1.Payload{Metadata: map[string][]byte{
+				test.LogLevelMetadataKey: []byte("loud"),
+			}},
+			wantErr: "invalid test input log-level metadata",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotParam workflowTestParam
+			var gotLevel *slog.Level
+			pt := newParamTest(func(t TestT, p workflowTestParam) {
+				gotParam = p
+				cfg, _ := temporal.TestLogConfigFromWorkflowContext(getWorkflowT(t).WorkflowContext())
+				gotLevel = cfg.LogLevels.Publish
+			}, defaultParam)
+
+			env := newTestWorkflowEnv(t, newTestRuntime())
+			env.RegisterWorkflowWithOptions(test.LogLevelTest(temporal.LogLevelOverrides{}, pt.workflow), workflow.RegisterOptions{
+				Name: testWorkflowName,
+			})
+			env.ExecuteWorkflow(testWorkflowName, tt.payload, nil)
+
+			require.True(t, env.IsWorkflowCompleted())
+			if tt.wantErr != "" {
+				require.Error(t, env.GetWorkflowError())
+				assert.ErrorContains(t, env.GetWorkflowError(), tt.wantErr)
+				return
+			}
+			require.NoError(t, env.GetWorkflowError())
+			assert.Equal(t, tt.wantParam, gotParam)
+			assert.Equal(t, tt.wantLevel, gotLevel)
+		})
+	}
+}
+
+func TestParamTest_ProtoInputAndResult(t *testing.T) {
+	protoCase := func(t CaseT, req *testsv1.PublishLogRequest) {
+		SetResult(t, &testsv1.PublishLogResponse{LogId: req.TestExecutionId + "/" + req.CreateTime.AsTime().Format(time.DateOnly)})
+	}
+
+	// Inputs edited in Annex are labelled as plain JSON.
+	payload := &testsv1.Payload{
+		Metadata: map[string][]byte{converter.MetadataEncoding: []byte(converter.MetadataEncodingJSON)},
+		Data:     []byte(`{"testExecutionId":"exec-1","createTime":"2024-01-02T03:04:05Z","caseExecutionId":3}`),
+	}
+
+	tests := []struct {
+		name string
+		dc   converter.DataConverter
+	}{
+		{name: "proto json", dc: NewDataConverter()},
+		{name: "binary proto", dc: NewBinaryProtoDataConverter()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotInput *testsv1.PublishLogRequest
+			var gotResult *testsv1.PublishLogResponse
+			pt := newParamTest(func(t TestT, p *testsv1.PublishLogRequest) {
+				gotInput = p
+				gotResult = RequireSuccessResult[*testsv1.PublishLogResponse](t, StartCase(t, protoCase, WithInput(p)))
+			}, &testsv1.PublishLogRequest{})
+
+			rt := newTestRuntime()
+			rt.DataConverter = tt.dc
+			c := &paramCase[*testsv1.PublishLogRequest]{caseFn: protoCase}
+			env := newTestWorkflowEnv(t, rt)
+			env.RegisterWorkflowWithOptions(pt.workflow, workflow.RegisterOptions{Name: testWorkflowName})
+			env.RegisterActivityWithOptions(c.activity, activity.RegisterOptions{Name: c.name()})
+			env.ExecuteWorkflow(testWorkflowName, payload, nil)
+			require.NoError(t, env.GetWorkflowError())
+
+			require.NotNil(t, gotInput)
+			assert.Equal(t, "exec-1", gotInput.TestExecutionId)
+			assert.Equal(t, int32(3), gotInput.GetCaseExecutionId())
+			assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), gotInput.CreateTime.AsTime())
+			require.NotNil(t, gotResult)
+			assert.Equal(t, "exec-1/2024-01-02", gotResult.LogId)
+		})
+	}
+}
+
+func TestParamTest_DefaultInputNotShared(t *testing.T) {
+	defaultParam := []string{"a", "b"}
+	var got [][]string
+	pt := newParamTest(func(t TestT, p []string) {
+		got = append(got, append([]string(nil), p...))
+		p[0] = "mutated"
+	}, defaultParam)
+
+	for range 2 {
+		env := newTestWorkflowEnv(t, newTestRuntime())
+		env.RegisterWorkflowWithOptions(pt.workflow, workflow.RegisterOptions{Name: testWorkflowName})
+		env.ExecuteWorkflow(testWorkflowName, nil, nil)
+		require.NoError(t, env.GetWorkflowError())
+	}
+	assert.Equal(t, [][]string{{"a", "b"}, {"a", "b"}}, got)
+	assert.Equal(t, []string{"a", "b"}, defaultParam)
+}
+
+func TestParamTest_RegistersSecrets(t *testing.T) {
+	type creds struct {
+		User     string `json:"user"`
+		Password string `json:"password" annex:"secret"`
+	}
+	input, err := converter.GetDefaultDataConverter().ToPayload(creds{User: "admin", Password: "input-pw"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		tester  tester
+		payload *testsv1.Payload
+		secret  string
+	}{
+		{
+			name:    "input",
+			tester:  newParamTest(func(TestT, creds) {}, creds{Password: "default-pw"}),
+			payload: &testsv1.Payload{Metadata: input.Metadata, Data: input.Data},
+			secret:  "input-pw",
+		},
+		{
+			name:   "default input",
+			tester: newParamTest(func(TestT, creds) {}, creds{Password: "default-pw"}),
+			secret: "default-pw",
+		},
+		{
+			name: "table row",
+			tester: &tableTest[creds]{
+				rows: map[string]creds{"a": {Password: "row-pw"}},
+				test: func(TestT, creds) {},
+			},
+			secret: "row-pw",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := newTestRuntime()
+			env := newTestWorkflowEnv(t, rt)
+			env.RegisterWorkflowWithOptions(tt.tester.workflow, workflow.RegisterOptions{Name: testWorkflowName})
+			env.ExecuteWorkflow(testWorkflowName, tt.payload, nil)
+			require.NoError(t, env.GetWorkflowError())
+
+			msg, _ := rt.Redactor.Redact("password is "+tt.secret, nil)
+			assert.Equal(t, "password is "+param.RedactedValue, msg)
+		})
+	}
+}
+
+func TestSetOutput_Memo(t *testing.T) {
+	type order struct {
+		ID    string `json:"id"`
+		Token string `json:"token" annex:"secret"`
+	}
+
+	tests := []struct {
+		name     string
+		output   any
+		wantMemo string
+	}{
+		{
+			name:     "secret fields redacted",
+			output:   order{ID: "order-1", Token: "s3cr3t"},
+			wantMemo: `{"id":"order-1","token":"` + param.RedactedValue + `"}`,
+		},
+		{
+			name:     "proto",
+			output:   &testsv1.PublishLogResponse{LogId: "log-1"},
+			wantMemo: `{"logId":"log-1"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotMemo json.RawMessage
+			tester := &simpleTest{test: func(t TestT) {
+				SetOutput(t, tt.output)
+				ctx := getWorkflowT(t).WorkflowContext()
+				payload := workflow.GetInfo(ctx).Memo.GetFields()[test.OutputMemoKey]
+				require.NoError(t, converter.GetDefaultDataConverter().FromPayload(payload, &gotMemo))
+			}}
+
+			rt := newTestRuntime()
+			rt.Secrets, _ = param.NewSecretCipher([]byte("0123456789abcdef0123456789abcdef"))
+			env := newTestWorkflowEnv(t, rt)
+			env.RegisterWorkflowWithOptions(tester.workflow, workflow.RegisterOptions{Name: testWorkflowName})
+			env.ExecuteWorkflow(testWorkflowName, nil, nil)
+			require.NoError(t, env.GetWorkflowError())
+			assert.JSONEq(t, tt.wantMemo, string(gotMemo))
+		})
+	}
+}
+
+// executeTestWorkflow runs a test workflow to completion,
+// following every continue-as-new, and returns the number of runs.
+func executeTestWorkflow(t *testing.T, rt *test.Runtime, wf test.TestExecutor, payload *testsv1.Payload) (int, error) {
+	var checkpoint *test.Checkpoint
+	for runs := 1; ; runs++ {
+		env := newTestWorkflowEnv(t, rt)
+		env.RegisterWorkflowWithOptions(wf, workflow.RegisterOptions{Name: testWorkflowName})
+		env.ExecuteWorkflow(testWorkflowName, payload, checkpoint)
+		require.True(t, env.IsWorkflowCompleted())
+
+		var canErr *workflow.ContinueAsNewError
+		if err := env.GetWorkflowError(); !errors.As(err, &canErr) {
+			return runs, err
+		}
+		checkpoint = nil
+		require.NoError(t, converter.GetDefaultDataConverter().FromPayloads(canErr.Input, &payload, &checkpoint))
+	}
+}
+
+func TestTableTest_ContinueAsNew(t *testing.T) {
+	type row struct {
+		Fail          bool `json:"fail"`
+		ContinueAsNew bool `json:"continueAsNew"`
+	}
+	var ran []string
+	tester := &tableTest[row]{
+		rows: map[string]row{
+			"a": {Fail: true},
+			"b": {ContinueAsNew: true},
+			"c": {},
+		},
+		test: func(t TestT, r row) {
+			var resumed bool
+			RestoreCheckpoint(t, &resumed)
+			ran = append(ran, fmt.Sprintf("%t/%t", r.ContinueAsNew, resumed))
+			require.False(t, r.Fail)
+			if r.ContinueAsNew && !resumed {
+				ContinueAsNew(t, true)
+			}
+		},
+	}
+
+	runs, err := executeTestWorkflow(t, newTestRuntime(), tester.workflow, nil)
+	assert.Equal(t, 2, runs)
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "1 of 3 table rows failed: a")
+	// Row b restores its state when it resumes; row c does not.
+	assert.Equal(t, []string{"false/false", "true/false", "true/true", "false/false"}, ran)
+}
+
+func TestRegisterTableTest(t *testing.T) {
+	type row struct {
+		N int `json:"n"`
+	}
+	var ran []string
+	runner := &TestSuiteRunner{runtime: newTestRuntime()}
+	RegisterTableTest(runner, "table", map[string]row{"one": {N: 1}, "two": {N: 2}}, func(t TestT, r row) {
+		var resumed bool
+		RestoreCheckpoint(t, &resumed)
+		ran = append(ran, strconv.Itoa(r.N))
+		if r.N == 1 && !resumed {
+			ContinueAsNew(t, true)
+		}
+	})
+
+	var names []string
+	for _, reg := range runner.registeredTests {
+		names = append(names, reg.name)
+	}
+	require.Equal(t, []string{"table/one", "table/two", "table"}, names)
+
+	tests := []struct {
+		name     string
+		test     string
+		wantRuns int
+		wantRan  []string
+	}{
+		{name: "row", test: "table/two", wantRuns: 1, wantRan: []string{"2"}},
+		{name: "row continued as new", test: "table/one", wantRuns: 2, wantRan: []string{"1", "1"}},
+		{name: "table", test: "table", wantRuns: 2, wantRan: []string{"1", "1", "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ran = nil
+			i := slices.IndexFunc(runner.registeredTests, func(reg registeredTest) bool {
+				return reg.name == tt.test
+			})
+			reg := runner.registeredTests[i]
+			runs, err := executeTestWorkflow(t, runner.runtime, test.LogLevelTest(reg.logLevels, reg.test.workflow), nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantRuns, runs)
+			assert.Equal(t, tt.wantRan, ran)
+		})
+	}
+}
+
+func TestContinueAsNew_UnresolvedCases(t *testing.T) {
+	tests := []struct {
+		name    string
+		test    func(t TestT)
+		wantErr string
+	}{
+		{
+			name: "resolved",
+			test: func(t TestT) {
+				RequireSuccess(t, StartCase(t, replayCase))
+				ContinueAsNew(t, nil)
+			},
+		},
+		{
+			name: "resolved by error",
+			test: func(t TestT) {
+				StartCase(t, replayCase, WithInput(make(chan int)))
+				ContinueAsNew(t, nil)
+			},
+		},
+		{
+			name: "unresolved",
+			test: func(t TestT) {
+				RequireSuccess(t, StartCase(t, replayCase))
+				StartCase(t, replayCase)
+				ContinueAsNew(t, nil)
+			},
+			wantErr: "1 started cases have not finished: replayCase (case 2)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var errs []string
+			tester := &simpleTest{test: func(t TestT) {
+				tt.test(&errorRecordingT{TestT: t, WorkflowT: getWorkflowT(t), errs: &errs})
+			}}
+			c := &simpleCase{caseFn: replayCase}
+			env := newTestWorkflowEnv(t, newTestRuntime())
+			env.RegisterWorkflowWithOptions(tester.workflow, workflow.RegisterOptions{Name: testWorkflowName})
+			env.RegisterActivityWithOptions(c.activity, activity.RegisterOptions{Name: c.name()})
+			env.ExecuteWorkflow(testWorkflowName, nil, nil)
+
+			err := env.GetWorkflowError()
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				require.Len(t, errs, 1)
+				assert.Contains(t, errs[0], tt.wantErr)
+				return
+			}
+			var canErr *workflow.ContinueAsNewError
+			assert.ErrorAs(t, err, &canErr)
+		})
+	}
+}
+
+// errorRecordingT records the assertion failures of a test body.
+type errorRecordingT struct {
+	TestT
+	sdktesting.WorkflowT
+	errs *[]string
+}
+
+func (t *errorRecordingT) Errorf(format string, args ...any) {
+	*t.errs = append(*t.errs, fmt.Sprintf(format, args...))
+	t.TestT.Errorf(format, args...)
+}
+
+func levelPtr(level slog.Level) *slog.Level {
+	return &level
+}